@@ -0,0 +1,147 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	detachFiles   bool
+	checkoutForce bool
+)
+
+// attachCmd represents the attach command
+var attachCmd = &cobra.Command{
+	Use:   "attach uid file...",
+	Short: "attach files to a snippet",
+	Long: `Attach copies files into the snippet, replacing files with the same name.
+With --remove the arguments are names of files to take out of the snippet.`,
+	PreRunE: ensureConfig,
+	Args:    cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		attacher := getAttacher()
+		sid := args[0]
+
+		for _, fn := range args[1:] {
+			if detachFiles {
+				errorGuard(attacher.Detach(sid, fn), "removing "+fn+" failed")
+				continue
+			}
+
+			data, err := ioutil.ReadFile(fn)
+			errorGuard(err, "reading "+fn+" failed")
+			fi, err := os.Stat(fn)
+			errorGuard(err, "reading "+fn+" failed")
+
+			a := &pipetdata.Attachment{Name: filepath.Base(fn), Mode: fi.Mode().Perm(), Data: data}
+			errorGuard(attacher.Attach(sid, a), "attaching "+fn+" failed")
+		}
+	},
+}
+
+// catCmd represents the cat command
+var catCmd = &cobra.Command{
+	Use:     "cat uid [file]",
+	Short:   "print a file of the snippet, or its text if no file is given",
+	PreRunE: ensureConfig,
+	Args:    cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			snip, err := getDataStore().Read(args[0])
+			errorGuard(err, "reading snippet failed")
+			fmt.Print(snip.Data)
+			return
+		}
+
+		a, err := getAttacher().Attachment(args[0], args[1])
+		errorGuard(err, "reading file failed")
+		os.Stdout.Write(a.Data)
+	},
+}
+
+// checkoutCmd represents the checkout command
+var checkoutCmd = &cobra.Command{
+	Use:     "checkout uid dir",
+	Short:   "write the files of a snippet to a directory",
+	PreRunE: ensureConfig,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := pipetdata.ReadAttachments(getDataStore(), args[0])
+		errorGuard(err, "reading snippet failed")
+		if len(files) == 0 {
+			errorGuard(errors.New(args[0]), "snippet has no files")
+		}
+
+		dir := args[1]
+		if !checkoutForce {
+			for _, a := range files {
+				if _, err := os.Stat(filepath.Join(dir, a.Name)); err == nil {
+					errorGuard(errors.New(filepath.Join(dir, a.Name)),
+						"file exists, use --force to overwrite")
+				}
+			}
+		}
+
+		errorGuard(os.MkdirAll(dir, 0755), "creating directory failed")
+		for _, a := range files {
+			fn := filepath.Join(dir, a.Name)
+			errorGuard(ioutil.WriteFile(fn, a.Data, a.Mode), "writing "+fn+" failed")
+			// WriteFile leaves the mode of existing files alone
+			errorGuard(os.Chmod(fn, a.Mode), "writing "+fn+" failed")
+		}
+		fmt.Printf("checked out %d files to %s\n", len(files), dir)
+	},
+}
+
+// getAttacher returns the configured store, if it can keep files
+func getAttacher() pipetdata.Attacher {
+	attacher, ok := getDataStore().(pipetdata.Attacher)
+	if !ok {
+		errorGuard(fmt.Errorf("%s", storeURI()), "store can not keep files with snippets")
+	}
+	return attacher
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(checkoutCmd)
+	attachCmd.Flags().BoolVar(&detachFiles, "remove", false, "remove the named files from the snippet")
+	checkoutCmd.Flags().BoolVar(&checkoutForce, "force", false, "overwrite existing files")
+}
@@ -0,0 +1,85 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var convertFrom string
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert destination",
+	Short: "Copy all snippets into another store",
+	Long: `Copies every snippet from the configured store (or --from) into the store
+given as destination, for e.g
+
+  pipet convert sqlite://~/pipet.db
+  pipet convert --from sqlite://~/pipet.db dir://~/snippets
+
+uids are kept, snippets already present in destination are overwritten.
+Point store in the config to the destination to start using it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var src pipetdata.Store
+		if convertFrom != "" {
+			s, err := pipetdata.Open(expandStoreURI(convertFrom))
+			errorGuard(err, "error accessing source store")
+			src = s
+		} else {
+			errorGuard(ensureConfig(cmd, args), "")
+			src = getDataStore()
+		}
+
+		dst, err := pipetdata.Open(expandStoreURI(args[0]))
+		errorGuard(err, "error accessing destination store")
+
+		n, err := pipetdata.CopyStore(dst, src)
+		if lerr, ok := err.(*pipetdata.ListError); ok {
+			for id, e := range lerr.Errs {
+				fmt.Fprintf(os.Stderr, "%s: %s: %v\n", Red("skipping broken snippet"), id, e)
+			}
+			err = nil
+		}
+		errorGuard(err, "converting store failed")
+		fmt.Printf("copied %d snippets to %s\n", n, Green(args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().StringVar(&convertFrom, "from", "", "store to read snippets from (default is the configured store)")
+}
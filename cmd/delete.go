@@ -32,40 +32,77 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	permanent bool
+	assumeYes bool
 )
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
-	Use:     "delete [uid]",
-	Short:   "Remove snippet from storage (this is irreversible!)",
-	Args:    cobra.MaximumNArgs(1),
+	Use:   "delete [uid...]",
+	Short: "Move snippet to the trash",
+	Long: `Moves the snippets to the trash, from where they can be brought back with
+pipet restore. With --permanent the snippets are removed for good (this is
+irreversible!). Without arguments snippets are picked interactively, tab
+selects more than one.`,
 	PreRunE: ensureConfig,
 	Run: func(cmd *cobra.Command, args []string) {
-		sid := ""
-		if len(args) == 0 {
-			s, err := searchFullSnippet()
-			errorGuard(err, "")
-			sid = s
-		} else {
-			sid = args[0]
-		}
-
 		dataStore := getDataStore()
-		snip, err := dataStore.Read(sid)
+		sids, err := snippetArgs(dataStore, args, false)
+		errorGuard(err, "")
+
+		sns, err := readSnippets(dataStore, sids)
 		errorGuard(err, "querying snippet failed")
 
-		fmt.Printf("Are your you want to %s '%s' [y/n]: ", Red("DELETE"), Green(snip.Meta.Title))
-		confirm := readLine()
-		if confirm == "y" || confirm == "yes" {
-			errorGuard(dataStore.Delete(sid), "program failed to delete")
+		// a single snippet is only asked about when it can't be restored
+		if permanent || len(sns) > 1 {
+			action := "move to trash"
+			if permanent {
+				action = Red("PERMANENTLY DELETE")
+			}
+			fmt.Printf("Going to %s:\n", action)
+			for _, snip := range sns {
+				fmt.Printf("  %s (%s)\n", Green(snip.Meta.Title), snip.Meta.UID)
+			}
+			if !assumeYes && !confirm("Are you sure?") {
+				return
+			}
+		}
+
+		remove, msg := dataStore.Archive, "delete"
+		if permanent {
+			remove, msg = dataStore.Delete, "permanently delete"
+		}
+		err = pipetdata.Batch(dataStore, fmt.Sprintf("%s %d snippets", msg, len(sns)), func() error {
+			for _, snip := range sns {
+				if err := remove(snip.Meta.UID); err != nil {
+					return errors.Wrap(err, snip.Meta.UID)
+				}
+			}
+			return nil
+		})
+		errorGuard(err, "program failed to delete")
+
+		switch {
+		case permanent:
 			fmt.Println("deleted!")
+		case len(sns) == 1:
+			fmt.Printf("moved '%s' to trash, use `pipet restore %s` to undo\n", Green(sns[0].Meta.Title), sns[0].Meta.UID)
+		default:
+			fmt.Printf("moved %d snippets to trash, use `pipet restore` to undo\n", len(sns))
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(deleteCmd)
-
-	// FIXME: add an archive
+	deleteCmd.Flags().BoolVarP(&permanent, "permanent", "p", false, "skip the trash and delete for good")
+	deleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "don't ask for confirmation")
+	addFilterFlags(deleteCmd, true)
 }
@@ -0,0 +1,108 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var fixProblems bool
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check the snippet store for broken files",
+	Long: `Reports snippet files with missing or broken front matter, a uid that
+doesn't match the file name, duplicate uids and stray files in the store.
+
+With --fix, front matter is regenerated where it is missing, uids are set from
+the file name and files with unreadable metadata are moved to .quarantine in
+the store.`,
+	Args:    cobra.NoArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+		checker, ok := dataStore.(pipetdata.Checker)
+		if !ok {
+			errorGuard(errors.New(storeURI()), "store can't be checked")
+		}
+
+		problems, err := checker.Check()
+		errorGuard(err, "checking store failed")
+
+		if len(problems) == 0 {
+			fmt.Println(Green("no problems found"))
+			return
+		}
+
+		failed := false
+		report := func() error {
+			for _, p := range problems {
+				switch {
+				case !fixProblems:
+					fmt.Println(Red(p.Kind.String()+":"), p.File, "-", p.Detail)
+				case !p.Fixable():
+					fmt.Println(Blue("skipped:"), p.File, "-", p.Kind, "needs manual attention")
+				default:
+					if err := checker.Repair(p); err != nil {
+						fmt.Println(Red("failed:"), p.File, "-", err)
+						failed = true
+					} else {
+						fmt.Println(Green("fixed:"), p.File, "-", p.Kind)
+					}
+				}
+			}
+			return nil
+		}
+		if fixProblems {
+			// repairs are recorded as one change in stores that keep history
+			errorGuard(pipetdata.Batch(dataStore, "doctor --fix", report), "recording repairs failed")
+		} else {
+			report()
+		}
+
+		if !fixProblems {
+			fmt.Println("run `pipet doctor --fix` to repair")
+		}
+		if failed {
+			errorGuard(errors.New("some problems could not be fixed"), "doctor")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&fixProblems, "fix", false, "repair what can be repaired")
+}
@@ -35,9 +35,10 @@ import (
 
 // editCmd represents the edit command
 var editCmd = &cobra.Command{
-	Use:   "edit [uid]",
-	Short: "edit snippet data",
-	Args:  cobra.MaximumNArgs(1),
+	Use:     "edit [uid]",
+	Short:   "edit snippet data",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: ensureConfig,
 	Run: func(cmd *cobra.Command, args []string) {
 		sid := ""
 		if len(args) == 0 {
@@ -49,11 +50,12 @@ var editCmd = &cobra.Command{
 		}
 
 		dataStore := getDataStore()
-		fn := dataStore.Fullpath(sid)
-		editSnippet(fn)
+		errorGuard(editStoredSnippet(dataStore, sid), "editing snippet failed")
+		recordUse("edit", sid)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(editCmd)
+	addFilterFlags(editCmd, true)
 }
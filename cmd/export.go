@@ -0,0 +1,139 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	exportOut  = ""
+	exportJSON = false
+	exportPick = false
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [uid...]",
+	Short: "Write every snippet to a bundle file",
+	Long: `Writes every snippet, the trash included, with its metadata to a bundle
+that pipet import bundle reads back into any store. Bundles are gzipped tar
+files of snippets, or a single json document with --json or an --out ending in
+.json. Snippets with attached files can't go in a bundle, name the other
+snippets to export the rest.
+
+Every export writes only the snippets given as arguments, or picked with
+--pick, if there are any.`,
+	Args:    cobra.ArbitraryArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+		sns, err := skipBroken(pipetdata.Dump(dataStore))
+		errorGuard(err, "reading store failed")
+		sns = exportSelection(dataStore, sns, args)
+
+		format := "tar"
+		if exportJSON || strings.HasSuffix(exportOut, ".json") {
+			format = "json"
+		}
+
+		withExportFile(func(w io.Writer) error {
+			return pipetdata.WriteBundle(w, format, sns)
+		})
+		if exportOut != "" {
+			fmt.Printf("exported %d snippets to %s\n", len(sns), Green(exportOut))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.PersistentFlags().StringVarP(&exportOut, "out", "O", "", "file to write to (default is stdout)")
+	exportCmd.Flags().BoolVar(&exportJSON, "json", false, "write a json bundle instead of a tarball")
+	exportCmd.PersistentFlags().BoolVar(&exportPick, "pick", false, "pick the snippets to export")
+}
+
+// exportSelection narrows sns down to the snippets named in args, or picked
+// with --pick. Without either every snippet is exported.
+func exportSelection(dataStore pipetdata.Store, sns []*pipetdata.Snippet, args []string) []*pipetdata.Snippet {
+	if len(args) == 0 && !exportPick {
+		return sns
+	}
+
+	sids := args
+	if len(sids) == 0 {
+		var err error
+		sids, err = pickSnippets(dataStore, sns)
+		errorGuard(err, "")
+	}
+
+	byUID := map[string]*pipetdata.Snippet{}
+	for _, s := range sns {
+		byUID[s.Meta.UID] = s
+	}
+
+	picked := []*pipetdata.Snippet{}
+	for _, sid := range sids {
+		s, ok := byUID[sid]
+		if !ok {
+			errorGuard(fmt.Errorf("%s", sid), "no such snippet")
+		}
+		picked = append(picked, s)
+	}
+	return picked
+}
+
+// withExportFile runs write on --out, or stdout. A partly written file is
+// removed if write fails.
+func withExportFile(write func(w io.Writer) error) {
+	if exportOut == "" {
+		errorGuard(write(os.Stdout), "export failed")
+		return
+	}
+
+	f, err := os.Create(expandHome(exportOut))
+	errorGuard(err, "creating export file failed")
+
+	err = write(f)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	errorGuard(err, "export failed")
+}
@@ -33,6 +33,8 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
 )
 
 // filepathCmd represents the filepath command
@@ -42,8 +44,11 @@ var filepathCmd = &cobra.Command{
 	PreRunE: ensureConfig,
 	Args:    cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		dataStore := getDataStore()
-		fmt.Println(dataStore.Fullpath(args[0]))
+		fileStore, ok := getDataStore().(pipetdata.FileStore)
+		if !ok {
+			errorGuard(fmt.Errorf("%s", storeURI()), "store does not keep snippets in files")
+		}
+		fmt.Println(fileStore.Fullpath(args[0]))
 	},
 }
 
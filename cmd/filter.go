@@ -0,0 +1,111 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	filterTags   []string
+	filterNoTags []string
+	filterTitle  string
+	filterBody   string
+	filterQuery  string
+)
+
+// addFilterFlags adds the flags narrowing down the snippets a command lists
+// or picks from. A command using --body for something else can leave it out,
+// body can still be matched in --query.
+func addFilterFlags(cmd *cobra.Command, body bool) {
+	cmd.Flags().StringSliceVar(&filterTags, "tag", nil, "only snippets with all these tags")
+	cmd.Flags().StringSliceVar(&filterNoTags, "no-tag", nil, "only snippets without any of these tags")
+	cmd.Flags().StringVar(&filterTitle, "title", "", "only snippets with this in the title")
+	if body {
+		cmd.Flags().StringVar(&filterBody, "body", "", "only snippets with this in the body")
+	}
+	cmd.Flags().StringVarP(&filterQuery, "query", "q", "",
+		`only snippets matching the query, for e.g 'tag:k8s AND (title:deploy OR body:kubectl) -tag:old'`)
+}
+
+// snippetFilter is the query the filter flags make up, nil if none is set
+func snippetFilter() (pipetdata.Query, error) {
+	qs := []pipetdata.Query{}
+	for _, t := range filterTags {
+		qs = append(qs, pipetdata.Term("tag", t))
+	}
+	for _, t := range filterNoTags {
+		qs = append(qs, pipetdata.Not(pipetdata.Term("tag", t)))
+	}
+	if filterTitle != "" {
+		qs = append(qs, pipetdata.Term("title", filterTitle))
+	}
+	if filterBody != "" {
+		qs = append(qs, pipetdata.Term("body", filterBody))
+	}
+	if filterQuery != "" {
+		q, err := pipetdata.ParseQuery(filterQuery)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad --query")
+		}
+		qs = append(qs, q)
+	}
+
+	if len(qs) == 0 {
+		return nil, nil
+	}
+	return pipetdata.And(qs...), nil
+}
+
+// filterSnippets keeps the snippets selected by the filter flags, reading
+// bodies first if the filter looks at them.
+func filterSnippets(dataStore pipetdata.Store, sns []*pipetdata.Snippet) ([]*pipetdata.Snippet, error) {
+	q, err := snippetFilter()
+	if err != nil || q == nil {
+		return sns, err
+	}
+
+	if q.NeedsBody() {
+		sns, err = skipBroken(readBodies(dataStore, sns))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pipetdata.FilterSnippets(sns, q), nil
+}
+
+// filtered reports if any filter flag is set
+func filtered() bool {
+	q, err := snippetFilter()
+	return err == nil && q != nil
+}
@@ -0,0 +1,138 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:     "log [uid]",
+	Short:   "show the history of a snippet",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		history, sid := historyArgs(args)
+
+		revs, err := history.Log(sid)
+		errorGuard(err, "reading history failed")
+
+		output := []string{"Revision | Date | Change"}
+		for _, r := range revs {
+			output = append(output, fmt.Sprintf("%s | %s | %s", Blue(r.Hash), r.Date, r.Message))
+		}
+		fmt.Println(columnize.SimpleFormat(output))
+	},
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [uid] [rev]",
+	Short: "show changes to a snippet since a revision",
+	Long: `Shows changes made to the snippet since rev, by default the revision
+before the latest one.`,
+	Args:    cobra.MaximumNArgs(2),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		history, sid := historyArgs(args)
+		rev, err := revisionArg(history, sid, args)
+		errorGuard(err, "")
+
+		diff, err := history.Diff(sid, rev)
+		errorGuard(err, "diffing snippet failed")
+		fmt.Print(diff)
+	},
+}
+
+// revertCmd represents the revert command
+var revertCmd = &cobra.Command{
+	Use:   "revert [uid] [rev]",
+	Short: "restore an older version of a snippet",
+	Long: `Restores the snippet as it was at rev, by default the revision before the
+latest one. The revert is recorded in history too, so it can be undone.`,
+	Args:    cobra.MaximumNArgs(2),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		history, sid := historyArgs(args)
+		rev, err := revisionArg(history, sid, args)
+		errorGuard(err, "")
+
+		errorGuard(history.Revert(sid, rev), "reverting snippet failed")
+		fmt.Printf("reverted %s to %s\n", sid, Blue(rev))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(revertCmd)
+}
+
+// historyArgs returns the store history and the snippet picked by the first
+// argument, or interactively.
+func historyArgs(args []string) (pipetdata.History, string) {
+	history, ok := getDataStore().(pipetdata.History)
+	if !ok {
+		errorGuard(errors.New("set git: true in config or use a git:// store"), "store does not keep history")
+	}
+
+	sid := ""
+	if len(args) == 0 {
+		s, err := searchFullSnippet()
+		errorGuard(err, "")
+		sid = s
+	} else {
+		sid = args[0]
+	}
+	return history, sid
+}
+
+// revisionArg is the second argument, or the revision before the latest.
+func revisionArg(history pipetdata.History, sid string, args []string) (string, error) {
+	if len(args) > 1 {
+		return args[1], nil
+	}
+
+	revs, err := history.Log(sid)
+	if err != nil {
+		return "", err
+	}
+	if len(revs) < 2 {
+		return "", errors.New("snippet has no earlier revisions")
+	}
+	return revs[1].Hash, nil
+}
@@ -0,0 +1,74 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var siteTitle = "Snippets"
+
+// exportHTMLCmd represents the export html command
+var exportHTMLCmd = &cobra.Command{
+	Use:   "html [uid...]",
+	Short: "Write snippets as a static html site",
+	Long: `Writes every live snippet as a static site into the --out directory: an
+index grouped by tag with a search box and a page per snippet with a
+highlighted body and a copy button. The site needs no server or network, open
+index.html in a browser or copy the directory to any static host. Exporting
+again into the same directory replaces the pages.`,
+	Args:    cobra.ArbitraryArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		if exportOut == "" {
+			errorGuard(errors.New("use --out to name the site directory"), "export failed")
+		}
+
+		dataStore := getDataStore()
+		sns, err := skipBroken(pipetdata.ReadAll(dataStore))
+		errorGuard(err, "reading store failed")
+		sns = exportSelection(dataStore, sns, args)
+
+		err = pipetdata.WriteSite(expandHome(exportOut), siteTitle, sns)
+		errorGuard(err, "export failed")
+
+		fmt.Printf("exported %d snippets to %s\n", len(sns), Green(exportOut))
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportHTMLCmd)
+	exportHTMLCmd.Flags().StringVar(&siteTitle, "title", siteTitle, "title of the site")
+}
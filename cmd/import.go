@@ -0,0 +1,112 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	onConflict = "skip"
+	dryRun     = false
+)
+
+// importCmd groups the importers
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import snippets from a bundle or another tool",
+	Long: `Imports snippets into the configured store. Snippets whose uid is already
+taken are skipped, overwritten or imported under a new uid as --on-conflict
+says, identical ones are left alone. --dry-run only reports what would happen.`,
+}
+
+// importBundleCmd represents the import bundle command
+var importBundleCmd = &cobra.Command{
+	Use:     "bundle file",
+	Short:   "Import a bundle written by pipet export",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(expandHome(args[0]))
+		errorGuard(err, "opening bundle failed")
+		defer f.Close()
+
+		sns, err := pipetdata.ReadBundle(f)
+		errorGuard(err, "reading bundle failed")
+
+		importSnippets(sns)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importBundleCmd)
+	importCmd.PersistentFlags().StringVar(&onConflict, "on-conflict", onConflict,
+		"what to do with snippets whose uid is taken: "+strings.Join(pipetdata.Collisions, "|"))
+	importCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", false, "only report what would be imported")
+}
+
+// importSnippets imports sns into the configured store and reports what
+// happened to each.
+func importSnippets(sns []*pipetdata.Snippet) {
+	on, err := pipetdata.ParseCollision(onConflict)
+	errorGuard(err, "bad --on-conflict")
+
+	actions, err := pipetdata.Import(getDataStore(), sns, on, dryRun)
+
+	counts := map[string]int{}
+	for _, a := range actions {
+		counts[a.Action]++
+		uid := a.UID
+		if a.From != "" {
+			uid = a.From + " -> " + a.UID
+		}
+		fmt.Printf("%-9s %s %s\n", a.Action, Green(a.Title), uid)
+	}
+	errorGuard(err, "import failed")
+
+	summary := []string{}
+	for _, action := range []string{pipetdata.Added, pipetdata.Replaced, pipetdata.Renamed,
+		pipetdata.Skipped, pipetdata.Unchanged} {
+		if counts[action] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[action], action))
+		}
+	}
+	if dryRun {
+		summary = append(summary, "dry run, nothing written")
+	}
+	fmt.Println(strings.Join(summary, ", "))
+}
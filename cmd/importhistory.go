@@ -0,0 +1,174 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	historyShell string
+	historyFile  string
+	historyLimit int
+)
+
+// importHistoryCmd represents the import history command
+var importHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Pick commands from shell history to keep as snippets",
+	Long: `Reads a bash, zsh or fish history file and shows the commands in fzf,
+most useful first: commands run often and long commands rank high, commands
+already stored as snippets are left out. Select any number with tab, each one
+becomes a snippet after asking for a title and tags.
+
+The shell defaults to the one in $SHELL, the file to $HISTFILE or the shell's
+usual history file.`,
+	Args:    cobra.NoArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		shell := historyShell
+		if shell == "" {
+			shell = filepath.Base(os.Getenv("SHELL"))
+		}
+
+		fn := historyFile
+		if fn == "" {
+			fn = defaultHistoryFile(shell)
+		}
+
+		f, err := os.Open(expandHome(fn))
+		errorGuard(err, "opening history failed")
+		defer f.Close()
+
+		entries, err := pipetdata.ReadHistory(f, shell)
+		errorGuard(err, "reading history failed")
+
+		stored, err := skipBroken(pipetdata.ReadAll(getDataStore()))
+		errorGuard(err, "reading store failed")
+		known := map[string]bool{}
+		for _, s := range stored {
+			known[strings.TrimSpace(s.Data)] = true
+		}
+
+		candidates := []pipetdata.HistoryEntry{}
+		for _, e := range pipetdata.RankHistory(entries) {
+			if !known[e.Command] {
+				candidates = append(candidates, e)
+			}
+		}
+		if historyLimit > 0 && len(candidates) > historyLimit {
+			candidates = candidates[:historyLimit]
+		}
+		if len(candidates) == 0 {
+			fmt.Println("no new commands in", fn)
+			return
+		}
+
+		searchText := ""
+		for i, e := range candidates {
+			line := strings.Replace(e.Command, "\n", " \\n ", -1)
+			searchText += fmt.Sprintf("%d\t%4d  %s\n", i, e.Count, line)
+		}
+		picked, err := fuzzyMultiWrapper(searchText, finderOptions{})
+		errorGuard(err, "picking commands failed")
+
+		sns := []*pipetdata.Snippet{}
+		for _, id := range picked {
+			i, err := strconv.Atoi(id)
+			errorGuard(err, "picking commands failed")
+			sns = append(sns, historySnippet(candidates[i].Command))
+		}
+
+		importSnippets(sns)
+	},
+}
+
+// defaultHistoryFile is where shell keeps its history unless told otherwise
+func defaultHistoryFile(shell string) string {
+	if fn := os.Getenv("HISTFILE"); fn != "" && shell != "fish" {
+		return fn
+	}
+
+	switch shell {
+	case "zsh":
+		return "~/.zsh_history"
+	case "fish":
+		dir := os.Getenv("XDG_DATA_HOME")
+		if dir == "" {
+			dir = "~/.local/share"
+		}
+		return filepath.Join(dir, "fish", "fish_history")
+	}
+	return "~/.bash_history"
+}
+
+// historySnippet asks for a title and tags for command
+func historySnippet(command string) *pipetdata.Snippet {
+	fmt.Printf("\n%s\n", Green(command))
+
+	title := strings.TrimSpace(strings.SplitN(command, "\n", 2)[0])
+	fmt.Printf("Title [%s]: ", title)
+	if t := readLine(); t != "" {
+		title = t
+	}
+
+	fmt.Printf("Tags (comma separated) [untagged]: ")
+	tags := []string{}
+	for _, t := range strings.Split(readLine(), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) == 0 {
+		tags = []string{"untagged"}
+	}
+
+	s := &pipetdata.Snippet{Data: command + "\n"}
+	s.Meta.Title = title
+	s.Meta.Tags = tags
+	return s
+}
+
+func init() {
+	importCmd.AddCommand(importHistoryCmd)
+
+	importHistoryCmd.Flags().StringVar(&historyShell, "shell", "",
+		"shell the history is from, one of "+strings.Join(pipetdata.HistoryShells, ", "))
+	importHistoryCmd.Flags().StringVar(&historyFile, "file", "", "history file to read")
+	importHistoryCmd.Flags().IntVar(&historyLimit, "limit", 0, "offer at most n commands, 0 for all")
+}
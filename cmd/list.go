@@ -31,12 +31,17 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
 )
 
 var (
-	full = false
+	full     = false
+	archived = false
+	sortBy   = ""
 )
 
 // listCmd represents the list command
@@ -44,14 +49,33 @@ var listCmd = &cobra.Command{
 	Use:     "list",
 	Short:   "list all snippets",
 	Long:    `Lists all snippets, by default it only prints the uid and title`,
-	PreRunE: ensureConfig,
+	Args:    cobra.NoArgs,
+	PreRunE: checkOutputFlags,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		dataStore := getDataStore()
 
-		sns, err := dataStore.List()
+		sns, err := listSnippets(dataStore, archived)
 		errorGuard(err, "listing store failed")
 
+		if sortBy != "" {
+			errorGuard(sortSnippets(sns, sortBy), "sorting failed")
+		}
+
+		if outputFormat != "" {
+			printSnippets(dataStore, sns)
+			return
+		}
+
+		tmpl, err := formatOrDefault("list")
+		errorGuard(err, "formatting failed")
+		if tmpl != nil {
+			out, err := formatSnippets(tmpl, dataStore, sns)
+			errorGuard(err, "formatting failed")
+			fmt.Print(out)
+			return
+		}
+
 		rendered := renderSnippetList(sns, true)
 		fmt.Println(rendered)
 	},
@@ -59,4 +83,9 @@ var listCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVar(&archived, "archived", false, "list snippets in the trash instead")
+	addOutputFlags(listCmd, true)
+	addFormatFlag(listCmd)
+	addFilterFlags(listCmd, true)
+	listCmd.Flags().StringVarP(&sortBy, "sort", "s", "", "sort by "+strings.Join(pipetdata.SortKeys, "|")+"|"+sortFrecency+", dates and most used first")
 }
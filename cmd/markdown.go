@@ -0,0 +1,99 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+// importMarkdownCmd represents the import markdown command
+var importMarkdownCmd = &cobra.Command{
+	Use:   "markdown file",
+	Short: "Import snippets from a markdown document",
+	Long: `Imports every heading with fenced code blocks under it as a snippet: the
+heading is the title, a "Tags: a, b" line the tags, the code blocks the body
+and any other text the description. Documents written by export markdown
+carry uids, other headings get one from their title, so importing a document
+again updates its snippets. Unlike other imports existing snippets are
+overwritten unless --on-conflict says otherwise.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flag("on-conflict").Changed {
+			onConflict = "overwrite"
+		}
+
+		f, err := os.Open(expandHome(args[0]))
+		errorGuard(err, "opening markdown failed")
+		defer f.Close()
+
+		sns, err := pipetdata.ReadMarkdown(f)
+		errorGuard(err, "reading markdown failed")
+
+		importSnippets(sns)
+	},
+}
+
+// exportMarkdownCmd represents the export markdown command
+var exportMarkdownCmd = &cobra.Command{
+	Use:   "markdown [uid...]",
+	Short: "Write snippets as a markdown document",
+	Long: `Writes every live snippet as a section of a single markdown document: the
+title as a heading, a tag line, the description and the body in a code block.
+Each section ends in an html comment with the uid and other metadata, which
+renders as nothing but lets import markdown update the same snippets.`,
+	Args:    cobra.ArbitraryArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+		sns, err := skipBroken(pipetdata.ReadAll(dataStore))
+		errorGuard(err, "reading store failed")
+		sns = exportSelection(dataStore, sns, args)
+		pipetdata.SortSnippets(sns, "title")
+
+		withExportFile(func(w io.Writer) error {
+			return pipetdata.WriteMarkdown(w, sns)
+		})
+		if exportOut != "" {
+			fmt.Printf("exported %d snippets to %s\n", len(sns), Green(exportOut))
+		}
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importMarkdownCmd)
+	exportCmd.AddCommand(exportMarkdownCmd)
+}
@@ -55,13 +55,13 @@ var newCmd = &cobra.Command{
 
 		dataStore := getDataStore()
 
-		fn, err := dataStore.New(title, *snippetTags...)
+		sid, err := dataStore.New(title, *snippetTags...)
 		errorGuard(err, "creating snippet failed")
 
-		err = editSnippet(fn)
+		err = editStoredSnippet(dataStore, sid)
 		errorGuard(err, "opening snippet editor failed")
 
-		fmt.Println("created a new snippet: ", fn)
+		fmt.Println("created a new snippet: ", sid)
 	},
 }
 
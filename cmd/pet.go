@@ -0,0 +1,98 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+// where pet keeps its snippets unless configured otherwise
+const petSnippetFile = "~/.config/pet/snippet.toml"
+
+// importPetCmd represents the import pet command
+var importPetCmd = &cobra.Command{
+	Use:   "pet [file]",
+	Short: "Import snippets from a pet snippet.toml",
+	Long: `Imports snippets from pet (github.com/knqyf263/pet), by default from
+` + petSnippetFile + `. Descriptions become titles and commands the
+snippet body, other keys like output are kept as extra metadata. Importing the
+same file again finds the snippets already imported.`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		fn := petSnippetFile
+		if len(args) > 0 {
+			fn = args[0]
+		}
+
+		f, err := os.Open(expandHome(fn))
+		errorGuard(err, "opening pet snippets failed")
+		defer f.Close()
+
+		sns, err := pipetdata.ReadPet(f)
+		errorGuard(err, "reading pet snippets failed")
+
+		importSnippets(sns)
+	},
+}
+
+// exportPetCmd represents the export pet command
+var exportPetCmd = &cobra.Command{
+	Use:   "pet [uid...]",
+	Short: "Write snippets as a pet snippet.toml",
+	Long: `Writes every live snippet in pet's toml format. Titles become
+descriptions, extra metadata is written as additional keys.`,
+	Args:    cobra.ArbitraryArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+		sns, err := skipBroken(pipetdata.ReadAll(dataStore))
+		errorGuard(err, "reading store failed")
+		sns = exportSelection(dataStore, sns, args)
+
+		withExportFile(func(w io.Writer) error {
+			return pipetdata.WritePet(w, sns)
+		})
+		if exportOut != "" {
+			fmt.Printf("exported %d snippets to %s\n", len(sns), Green(exportOut))
+		}
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importPetCmd)
+	exportCmd.AddCommand(exportPetCmd)
+}
@@ -0,0 +1,153 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/dbalan/pipet/finder"
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+// previewCmd is run by fzf to show the snippet under the cursor
+var previewCmd = &cobra.Command{
+	Use:     "preview uid",
+	Short:   "show a snippet in the picker's preview window",
+	Hidden:  true,
+	PreRunE: ensureConfig,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// fzf renders colours in the preview but stdout is a pipe
+		color.NoColor = false
+		fmt.Print(previewText(getDataStore(), strings.TrimSpace(args[0])))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+}
+
+// previewText is what the picker shows for a snippet
+func previewText(dataStore pipetdata.Store, sid string) string {
+	snip, err := dataStore.Read(sid)
+	if err != nil {
+		return Red("reading snippet failed: ") + err.Error()
+	}
+	return fancySnippet(snip)
+}
+
+// previewConfig is the preview section of the config:
+//
+//	preview:
+//	  position: right # left, up or down
+//	  size: 50%
+//	  wrap: false
+//	  hidden: false
+type previewConfig struct {
+	position string
+	size     int
+	wrap     bool
+	hidden   bool
+}
+
+func getPreviewConfig() (previewConfig, error) {
+	c := previewConfig{
+		position: viper.GetString("preview.position"),
+		size:     50,
+		wrap:     viper.GetBool("preview.wrap"),
+		hidden:   viper.GetBool("preview.hidden"),
+	}
+
+	if c.position == "" {
+		c.position = "right"
+	}
+	valid := false
+	for _, p := range finder.PreviewPositions {
+		valid = valid || p == c.position
+	}
+	if !valid {
+		return c, fmt.Errorf("unknown preview position %q, use one of %s",
+			c.position, strings.Join(finder.PreviewPositions, ", "))
+	}
+
+	if size := viper.GetString("preview.size"); size != "" {
+		n, err := strconv.Atoi(strings.TrimSuffix(size, "%"))
+		if err != nil || n < 1 || n > 99 {
+			return c, fmt.Errorf("bad preview size %q, it is a percentage of the screen", size)
+		}
+		c.size = n
+	}
+	return c, nil
+}
+
+// fzfArgs makes fzf run `pipet preview` for the uid of the current line
+func (c previewConfig) fzfArgs() ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "finding pipet failed")
+	}
+
+	command := shellQuote(exe)
+	if cfgFile != "" {
+		command += " --config " + shellQuote(cfgFile)
+	}
+	command += " preview {1}"
+
+	window := fmt.Sprintf("%s:%d%%", c.position, c.size)
+	if c.wrap {
+		window += ":wrap"
+	}
+	return []string{"--preview", command, "--preview-window", window}, nil
+}
+
+// finderPreview is the built-in finder's preview of snippets in dataStore
+func (c previewConfig) finderPreview(dataStore pipetdata.Store) *finder.Preview {
+	return &finder.Preview{
+		Text: func(sid string) string {
+			return previewText(dataStore, sid)
+		},
+		Position: c.position,
+		Size:     c.size,
+		Wrap:     c.wrap,
+	}
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
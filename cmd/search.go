@@ -0,0 +1,121 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	pick        = false
+	searchLimit = 0
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search query...",
+	Short: "full text search in titles, tags and snippets",
+	Long: `Searches titles, tags and snippet bodies, best matches first.
+
+All words have to match, foo* matches words starting with foo and "quoted
+words" have to appear next to each other. Matches in titles and tags rank
+above matches in the body.`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+
+		sns, err := skipBroken(pipetdata.ReadAll(dataStore))
+		errorGuard(err, "reading store failed")
+
+		results := pipetdata.NewSearchIndex(sns).Search(strings.Join(args, " "))
+		if len(results) == 0 {
+			errorGuard(errors.New("no snippets found"), "search failed")
+		}
+		if searchLimit > 0 && len(results) > searchLimit {
+			results = results[:searchLimit]
+		}
+
+		if pick {
+			found := []*pipetdata.Snippet{}
+			for _, r := range results {
+				found = append(found, r.Snippet)
+			}
+			sid, err := pickSnippet(dataStore, found)
+			errorGuard(err, "searching failed")
+
+			snip, err := dataStore.Read(sid)
+			errorGuard(err, "reading snippet failed")
+			printSnippet(dataStore, snip)
+			return
+		}
+
+		if outputFormat != "" {
+			found := []*pipetdata.Snippet{}
+			for _, r := range results {
+				found = append(found, r.Snippet)
+			}
+			printSnippets(dataStore, found)
+			return
+		}
+
+		for _, r := range results {
+			fmt.Printf("%s [%s] %s\n", Green(r.Snippet.Meta.Title),
+				Blue(strings.Join(r.Snippet.Meta.Tags, ",")), r.Snippet.Meta.UID)
+			for _, l := range r.Lines {
+				fmt.Printf("  %d: %s\n", l.Number, highlight(l))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().BoolVarP(&pick, "pick", "p", false, "choose from the results with fzf and show the snippet")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 0, "show at most this many results")
+	addOutputFlags(searchCmd, true)
+}
+
+// highlight colors the matching words of a line
+func highlight(l pipetdata.MatchLine) string {
+	out := ""
+	last := 0
+	for _, s := range l.Spans {
+		out += l.Text[last:s[0]] + Red(l.Text[s[0]:s[1]])
+		last = s[1]
+	}
+	return out + l.Text[last:]
+}
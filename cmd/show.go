@@ -31,37 +31,51 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 
 	"github.com/dbalan/pipet/pipetdata"
 )
 
 var body bool
 
+// dateFormat is used to show snippet timestamps
+const dateFormat = "2006-01-02 15:04"
+
 // showCmd represents the show command
 var showCmd = &cobra.Command{
-	Use:     "show [uid]",
-	Short:   "display the snippet",
-	Args:    cobra.MaximumNArgs(1),
-	PreRunE: ensureConfig,
+	Use:   "show [uid...]",
+	Short: "display the snippet",
+	Long: `Displays the snippets, without arguments they are picked interactively and
+tab selects more than one.`,
+	PreRunE: checkOutputFlags,
 	Run: func(cmd *cobra.Command, args []string) {
-		sid := ""
-		if len(args) == 0 {
-			s, err := searchFullSnippet()
-			errorGuard(err, "")
-			sid = s
-		} else {
-			sid = args[0]
-		}
-
 		dataStore := getDataStore()
-		snip, err := dataStore.Read(sid)
+		sids, err := snippetArgs(dataStore, args, archived)
+		errorGuard(err, "")
+
+		sns, err := readSnippets(dataStore, sids)
 		errorGuard(err, "reading snippet failed")
-		if body {
-			fmt.Printf(snip.Data)
+		if outputFormat != "" {
+			// a list of records even for one snippet, scripts see the same shape
+			errorGuard(pipetdata.WriteRecords(os.Stdout, outputFormat, sns, true), "writing output failed")
 		} else {
-			fmt.Printf(fancySnippet(snip))
+			for i, snip := range sns {
+				if body && formatSpec == "" {
+					fmt.Print(snip.Data)
+					continue
+				}
+				if i > 0 {
+					fmt.Println()
+				}
+				printSnippet(dataStore, snip)
+			}
+		}
+		if !archived {
+			recordUse("show", sids...)
 		}
 	},
 }
@@ -69,6 +83,10 @@ var showCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(showCmd)
 	showCmd.PersistentFlags().BoolVarP(&body, "body-only", "b", false, "show only snippet content")
+	showCmd.Flags().BoolVar(&archived, "archived", false, "pick from snippets in the trash")
+	addOutputFlags(showCmd, false)
+	addFormatFlag(showCmd)
+	addFilterFlags(showCmd, true)
 }
 
 func fancySnippet(s *pipetdata.Snippet) string {
@@ -78,7 +96,37 @@ func fancySnippet(s *pipetdata.Snippet) string {
 	for _, t := range s.Meta.Tags {
 		text += Green("- ") + Blue(t) + "\n"
 	}
+	if s.Meta.Created != nil {
+		text += Green("Created: ") + s.Meta.Created.Format(dateFormat) + "\n"
+	}
+	if s.Meta.Updated != nil {
+		text += Green("Updated: ") + s.Meta.Updated.Format(dateFormat) + "\n"
+	}
+	for _, f := range s.Meta.Extra {
+		text += Green(fmt.Sprintf("%v:", f.Key)) + fieldValue(f.Value) + "\n"
+	}
+	if len(s.Meta.Files) > 0 {
+		text += Green("Files:\n")
+		for _, f := range s.Meta.Files {
+			text += Green("- ") + f + "\n"
+		}
+	}
 	text += sep
 	text += s.Data
 	return text
 }
+
+// fieldValue renders the value of an extra metadata field to follow its key,
+// nested values are shown as indented yaml.
+func fieldValue(v interface{}) string {
+	switch v.(type) {
+	case yaml.MapSlice, []interface{}, map[interface{}]interface{}:
+		buf, err := yaml.Marshal(v)
+		if err != nil {
+			return " " + fmt.Sprint(v)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+		return "\n  " + strings.Join(lines, "\n  ")
+	}
+	return " " + fmt.Sprint(v)
+}
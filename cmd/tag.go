@@ -0,0 +1,115 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var (
+	addTags    []string
+	removeTags []string
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag [uid...]",
+	Short: "add or remove tags of snippets",
+	Long: `Adds the --add tags to the snippets and takes away the --remove ones.
+Without arguments snippets are picked interactively, tab selects more than
+one.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(addTags) == 0 && len(removeTags) == 0 {
+			return errors.New("nothing to do, use --add or --remove")
+		}
+		return ensureConfig(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+		sids, err := snippetArgs(dataStore, args, false)
+		errorGuard(err, "")
+
+		sns, err := readSnippets(dataStore, sids)
+		errorGuard(err, "reading snippet failed")
+
+		changed := 0
+		err = pipetdata.Batch(dataStore, fmt.Sprintf("retag %d snippets", len(sns)), func() error {
+			for _, s := range sns {
+				if !retag(s, addTags, removeTags) {
+					continue
+				}
+				s.Touch()
+				if err := dataStore.Write(s); err != nil {
+					return errors.Wrap(err, s.Meta.UID)
+				}
+				changed++
+			}
+			return nil
+		})
+		errorGuard(err, "tagging failed")
+		fmt.Printf("updated tags of %d snippets\n", changed)
+	},
+}
+
+// retag adds and removes tags of s, keeping the order of the others. It
+// reports if anything changed.
+func retag(s *pipetdata.Snippet, add, remove []string) bool {
+	drop := map[string]bool{}
+	for _, t := range remove {
+		drop[t] = true
+	}
+
+	tags := []string{}
+	have := map[string]bool{}
+	for _, t := range append(append([]string{}, s.Meta.Tags...), add...) {
+		if !drop[t] && !have[t] {
+			tags = append(tags, t)
+			have[t] = true
+		}
+	}
+
+	changed := len(tags) != len(s.Meta.Tags)
+	for i := 0; !changed && i < len(tags); i++ {
+		changed = tags[i] != s.Meta.Tags[i]
+	}
+	s.Meta.Tags = tags
+	return changed
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.Flags().StringSliceVar(&addTags, "add", nil, "tags to add, comma separated or repeated")
+	tagCmd.Flags().StringSliceVar(&removeTags, "remove", nil, "tags to remove, comma separated or repeated")
+}
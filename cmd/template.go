@@ -0,0 +1,249 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+var formatSpec = ""
+
+// addFormatFlag adds --format to a command printing snippets
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&formatSpec, "format", "f", "",
+		"go template, or the name of one under templates in the config")
+}
+
+// snippetView is what --format templates are executed with. Body is a method
+// so it is only read when a template uses it.
+type snippetView struct {
+	UID           string
+	Title         string
+	Tags          []string
+	Created       *time.Time
+	Updated       *time.Time
+	Archived      *time.Time
+	FormatVersion int
+	Extra         map[string]interface{}
+	Files         []string
+
+	snippet   *pipetdata.Snippet
+	dataStore pipetdata.Store
+}
+
+func newSnippetView(dataStore pipetdata.Store, s *pipetdata.Snippet) snippetView {
+	extra, _ := plainValue(s.Meta.Extra).(map[string]interface{})
+	return snippetView{
+		UID:           s.Meta.UID,
+		Title:         s.Meta.Title,
+		Tags:          s.Meta.Tags,
+		Created:       s.Meta.Created,
+		Updated:       s.Meta.Updated,
+		Archived:      s.Meta.Archived,
+		FormatVersion: s.Meta.FormatVersion,
+		Extra:         extra,
+		Files:         s.Meta.Files,
+		snippet:       s,
+		dataStore:     dataStore,
+	}
+}
+
+// Body returns the snippet text, reading it from the store if it was listed
+// without.
+func (v snippetView) Body() (string, error) {
+	if v.snippet.Data != "" || v.dataStore == nil {
+		return v.snippet.Data, nil
+	}
+	full, err := v.dataStore.Read(v.UID)
+	if err != nil {
+		return "", err
+	}
+	v.snippet.Data = full.Data
+	return full.Data, nil
+}
+
+// plainValue turns decoded yaml into plain maps, so templates can use
+// {{.Extra.source.url}}.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		m := map[string]interface{}{}
+		for _, item := range v {
+			m[fmt.Sprint(item.Key)] = plainValue(item.Value)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[fmt.Sprint(k)] = plainValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = plainValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+var templateFuncs = template.FuncMap{
+	"join": func(l []string, sep string) string { return strings.Join(l, sep) },
+	"date": func(t *time.Time, layout ...string) string {
+		if t == nil {
+			return ""
+		}
+		if len(layout) > 0 {
+			return t.Format(layout[0])
+		}
+		return t.Format(dateFormat)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"green": func(a ...interface{}) string { return Green(a...) },
+	"blue":  func(a ...interface{}) string { return Blue(a...) },
+	"red":   func(a ...interface{}) string { return Red(a...) },
+}
+
+// namedTemplate returns the template configured under templates.name
+func namedTemplate(name string) (string, bool) {
+	text, ok := viper.GetStringMapString("templates")[strings.ToLower(name)]
+	return text, ok
+}
+
+// escapes in template text, so \t works inside single quotes on the shell
+var templateEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// expandEscapes replaces escapes in the text around actions, inside {{ }}
+// they are left for the template's own string literals.
+func expandEscapes(text string) string {
+	out := ""
+	for {
+		start := strings.Index(text, "{{")
+		if start == -1 {
+			return out + templateEscapes.Replace(text)
+		}
+		end := actionEnd(text[start:])
+		if end == -1 {
+			// unterminated, Parse reports it
+			return out + templateEscapes.Replace(text[:start]) + text[start:]
+		}
+		out += templateEscapes.Replace(text[:start]) + text[start:start+end]
+		text = text[start+end:]
+	}
+}
+
+// actionEnd returns the index just past the }} closing the action text starts
+// with, skipping over quoted strings. It is -1 if the action isn't closed.
+func actionEnd(text string) int {
+	quote := byte(0)
+	for i := 2; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case c == '}' && i+1 < len(text) && text[i+1] == '}':
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// parseFormat parses a --format value, either a template named in the config
+// or template text.
+func parseFormat(spec string) (*template.Template, error) {
+	name, text := "format", spec
+	if t, ok := namedTemplate(spec); ok {
+		name, text = spec, t
+	}
+	text = expandEscapes(text)
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad template")
+	}
+	return tmpl, nil
+}
+
+// renderTemplate executes tmpl for a snippet
+func renderTemplate(tmpl *template.Template, dataStore pipetdata.Store, s *pipetdata.Snippet) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, newSnippetView(dataStore, s)); err != nil {
+		return "", errors.Wrap(err, "rendering template failed")
+	}
+	return out.String(), nil
+}
+
+// formatSnippets renders every snippet on its own line
+func formatSnippets(tmpl *template.Template, dataStore pipetdata.Store, sns []*pipetdata.Snippet) (string, error) {
+	out := ""
+	for _, s := range sns {
+		line, err := renderTemplate(tmpl, dataStore, s)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		out += line
+	}
+	return out, nil
+}
+
+// formatOrDefault parses --format, falling back to the template configured
+// under templates.name. It is nil if neither is set.
+func formatOrDefault(name string) (*template.Template, error) {
+	spec := formatSpec
+	if spec == "" {
+		if _, ok := namedTemplate(name); !ok {
+			return nil, nil
+		}
+		spec = name
+	}
+	return parseFormat(spec)
+}
@@ -0,0 +1,180 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+var olderThan string
+
+// trashCmd represents the trash command
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage deleted snippets",
+}
+
+// trashListCmd represents the trash list command
+var trashListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list snippets in the trash",
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		sns, err := listSnippets(getDataStore(), true)
+		errorGuard(err, "listing trash failed")
+
+		if len(sns) == 0 {
+			fmt.Println("trash is empty")
+			return
+		}
+
+		// newest first, snippets without a deletion date last
+		sort.SliceStable(sns, func(i, j int) bool {
+			a, b := sns[i].Meta.Archived, sns[j].Meta.Archived
+			return a != nil && (b == nil || a.After(*b))
+		})
+
+		output := []string{"Deleted | Title | Tags | UID"}
+		for _, s := range sns {
+			deleted := "unknown"
+			if s.Meta.Archived != nil {
+				deleted = s.Meta.Archived.Format(dateFormat)
+			}
+			output = append(output, fmt.Sprintf("%s | %s | %s | %s",
+				deleted, Green(s.Meta.Title),
+				Blue(strings.Join(s.Meta.Tags, ",")), s.Meta.UID))
+		}
+		fmt.Println(columnize.SimpleFormat(output))
+	},
+}
+
+// trashEmptyCmd represents the trash empty command
+var trashEmptyCmd = &cobra.Command{
+	Use:     "empty",
+	Short:   "permanently delete snippets in the trash",
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		cutoff := time.Now()
+		if olderThan != "" {
+			age, err := parseAge(olderThan)
+			errorGuard(err, "bad --older-than")
+			cutoff = cutoff.Add(-age)
+		}
+
+		dataStore := getDataStore()
+		sns, err := listSnippets(dataStore, true)
+		errorGuard(err, "listing trash failed")
+
+		expired := []string{}
+		for _, s := range sns {
+			// without a deletion date the age is unknown, only emptying
+			// everything removes it
+			if s.Meta.Archived == nil && olderThan == "" ||
+				s.Meta.Archived != nil && s.Meta.Archived.Before(cutoff) {
+				expired = append(expired, s.Meta.UID)
+			}
+		}
+
+		if len(expired) == 0 {
+			fmt.Println("nothing to remove")
+			return
+		}
+
+		question := fmt.Sprintf("Are you sure you want to %s %d snippets?", Red("PERMANENTLY DELETE"), len(expired))
+		if !assumeYes && !confirm(question) {
+			return
+		}
+
+		for _, sid := range expired {
+			errorGuard(dataStore.Delete(sid), "program failed to delete")
+		}
+		fmt.Printf("removed %d snippets from trash\n", len(expired))
+	},
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:     "restore [uid]",
+	Short:   "bring a deleted snippet back from the trash",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		sid := ""
+		if len(args) == 0 {
+			s, err := searchSnippets(true)
+			errorGuard(err, "")
+			sid = s
+		} else {
+			sid = args[0]
+		}
+
+		dataStore := getDataStore()
+		errorGuard(dataStore.Restore(sid), "restoring snippet failed")
+		fmt.Println("restored", sid)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	rootCmd.AddCommand(restoreCmd)
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashEmptyCmd)
+
+	trashEmptyCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "don't ask for confirmation")
+	trashEmptyCmd.Flags().StringVar(&olderThan, "older-than", "", "only remove snippets deleted before this long ago, for e.g 30d or 12h")
+}
+
+// parseAge parses durations like time.ParseDuration, additionally accepting
+// days (d) and weeks (w) as units.
+func parseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil || n < 0 {
+				return 0, errors.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,262 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+// sortFrecency orders snippets by how often and how recently they were used
+const sortFrecency = "frecency"
+
+var recentCount int
+
+// usageLog returns the configured usage log, nil if it is turned off with
+// `usage_log: none`.
+func usageLog() *pipetdata.UsageLog {
+	path := viper.GetString("usage_log")
+	switch path {
+	case "none":
+		return nil
+	case "":
+		path = "~/.pipet_usage"
+	}
+	return pipetdata.NewUsageLog(expandHome(path))
+}
+
+// recordUse notes that snippets were used, a failure only warns as the
+// command itself succeeded.
+func recordUse(action string, sids ...string) {
+	l := usageLog()
+	if l == nil {
+		return
+	}
+	for _, sid := range sids {
+		if err := l.Record(action, sid); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", Red("recording use failed"), err)
+			return
+		}
+	}
+}
+
+// readUses returns every use in the usage log
+func readUses() ([]pipetdata.Use, error) {
+	l := usageLog()
+	if l == nil {
+		return []pipetdata.Use{}, nil
+	}
+	return l.Uses()
+}
+
+// sortSnippets sorts by one of pipetdata.SortKeys or by frecency
+func sortSnippets(sns []*pipetdata.Snippet, key string) error {
+	if key != sortFrecency {
+		return pipetdata.SortSnippets(sns, key)
+	}
+
+	uses, err := readUses()
+	if err != nil {
+		return err
+	}
+	pipetdata.SortByFrecency(sns, pipetdata.Frecency(uses, time.Now()))
+	return nil
+}
+
+// sortPicker orders the snippets offered in the picker as `picker_sort` says,
+// most used first by default.
+func sortPicker(sns []*pipetdata.Snippet) error {
+	key := viper.GetString("picker_sort")
+	switch key {
+	case "none":
+		return nil
+	case "":
+		key = sortFrecency
+	}
+	return errors.Wrap(sortSnippets(sns, key), "bad picker_sort in config")
+}
+
+// pickOne returns the uid given as argument or lets the user pick a snippet
+func pickOne(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	sid, err := searchFullSnippet()
+	errorGuard(err, "")
+	return sid
+}
+
+// copyCmd represents the copy command
+var copyCmd = &cobra.Command{
+	Use:   "copy [uid]",
+	Short: "copy the snippet text to the clipboard",
+	Long: `Copies the snippet text to the clipboard with pbcopy, wl-copy, xclip, xsel
+or clip.exe, whichever is installed. Set clipboard in the config to use
+another command, it gets the text on stdin.`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		sid := pickOne(args)
+		snip, err := getDataStore().Read(sid)
+		errorGuard(err, "reading snippet failed")
+
+		clip, err := clipboardCommand()
+		errorGuard(err, "copying failed")
+		c := exec.Command(clip[0], clip[1:]...)
+		c.Stdin = strings.NewReader(snip.Data)
+		c.Stderr = os.Stderr
+		errorGuard(c.Run(), "copying failed")
+		recordUse("copy", sid)
+	},
+}
+
+// clipboards are tried in order when no clipboard is configured
+var clipboards = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// clipboardCommand is the command copying its stdin to the clipboard
+func clipboardCommand() ([]string, error) {
+	if c := viper.GetString("clipboard"); c != "" {
+		return strings.Fields(c), nil
+	}
+	for _, c := range clipboards {
+		if _, err := which(c[0]); err == nil {
+			return c, nil
+		}
+	}
+	return nil, errors.New("no clipboard command found, set clipboard in the config")
+}
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run [uid]",
+	Short: "run the snippet as a shell command",
+	Long: `Runs the snippet text with $SHELL -c, or sh if SHELL isn't set. Arguments
+after -- are passed on as $1, $2 and so on.`,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		var extra []string
+		if i := cmd.ArgsLenAtDash(); i != -1 {
+			args, extra = args[:i], args[i:]
+		}
+		if len(args) > 1 {
+			errorGuard(errors.New("only one uid, pass arguments after --"), "")
+		}
+
+		sid := pickOne(args)
+		snip, err := getDataStore().Read(sid)
+		errorGuard(err, "reading snippet failed")
+
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "sh"
+		}
+		c := exec.Command(shell, append([]string{"-c", snip.Data, shell}, extra...)...)
+		c.Stdin = os.Stdin
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		recordUse("run", sid)
+
+		if err := c.Run(); err != nil {
+			if exit, ok := err.(*exec.ExitError); ok {
+				os.Exit(exitCode(exit))
+			}
+			errorGuard(err, "running snippet failed")
+		}
+	},
+}
+
+// exitCode is the status a command exited with, 1 if it was killed
+func exitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.ExitStatus() > 0 {
+		return status.ExitStatus()
+	}
+	return 1
+}
+
+// recentCmd represents the recent command
+var recentCmd = &cobra.Command{
+	Use:     "recent",
+	Short:   "list the snippets used last",
+	Long:    `Lists the snippets last shown, edited, copied or run, newest first.`,
+	Args:    cobra.NoArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		uses, err := readUses()
+		errorGuard(err, "reading usage log failed")
+
+		dataStore := getDataStore()
+		output := []string{"Used | Title | Tags | UID"}
+		for _, u := range pipetdata.Recent(uses, 0) {
+			if recentCount > 0 && len(output) > recentCount {
+				break
+			}
+			// deleted snippets drop out of the list
+			s, err := dataStore.Read(u.UID)
+			if err != nil || s.Meta.Archived != nil {
+				continue
+			}
+			output = append(output, fmt.Sprintf("%s | %s | %s | %s",
+				u.Time.Local().Format(dateFormat), Green(s.Meta.Title),
+				Blue(strings.Join(s.Meta.Tags, ",")), s.Meta.UID))
+		}
+
+		if len(output) == 1 {
+			fmt.Println("no snippets used yet")
+			return
+		}
+		fmt.Println(columnize.SimpleFormat(output))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(recentCmd)
+	addFilterFlags(copyCmd, true)
+	addFilterFlags(runCmd, true)
+	recentCmd.Flags().IntVarP(&recentCount, "number", "n", 10, "how many snippets to list, 0 for all")
+}
@@ -33,6 +33,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -45,6 +47,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/dbalan/pipet/finder"
 	"github.com/dbalan/pipet/pipetdata"
 )
 
@@ -88,19 +91,46 @@ func isValidDirectory(p string) bool {
 }
 
 func ensureConfig(cmd *cobra.Command, args []string) error {
-	if _, ok := viper.Get("document_dir").(string); !ok {
-		return errors.New("no document_dir set in config, run pipet init")
+	if storeURI() == "" {
+		return errors.New("no document_dir or store set in config, run pipet init")
 	}
 
 	if _, ok := viper.Get("editor_binary").(string); !ok {
 		return errors.New("no editor_binary set in conifg, run pipet init")
 	}
+
+	if f := viper.GetString("front_matter"); f != "" {
+		if err := pipetdata.SetFrontMatter(f); err != nil {
+			return errors.Wrap(err, "bad front_matter in config")
+		}
+	}
 	return nil
 }
 
-func getDataStore() *pipetdata.DataStore {
-	diskPath := viper.Get("document_dir").(string)
-	dataStore, err := pipetdata.NewDataStore(expandHome(diskPath))
+// storeURI returns the configured store, `store` takes precedence over the
+// older `document_dir` key. With `git: true` document_dir is kept under git.
+func storeURI() string {
+	uri := viper.GetString("store")
+	if uri == "" {
+		uri = viper.GetString("document_dir")
+		if uri != "" && viper.GetBool("git") {
+			// uri paths are unescaped, document_dir is a plain path
+			uri = "git://" + (&url.URL{Path: uri}).EscapedPath()
+		}
+	}
+	return expandStoreURI(uri)
+}
+
+// expandStoreURI expands ~/ in the path part of a store uri
+func expandStoreURI(uri string) string {
+	if i := strings.Index(uri, "://"); i != -1 {
+		return uri[:i+3] + expandHome(uri[i+3:])
+	}
+	return expandHome(uri)
+}
+
+func getDataStore() pipetdata.Store {
+	dataStore, err := pipetdata.Open(storeURI())
 	errorGuard(err, "error accessing data store")
 	return dataStore
 }
@@ -129,21 +159,70 @@ func editSnippet(fn string) error {
 	return nil
 }
 
-func parseOutput(out string) (string, error) {
-	out = strings.TrimSuffix(out, "\n")
-	oli := strings.Split(out, " ")
+// editStoredSnippet hands a copy of the snippet to the editor and writes the
+// result back to the store. If the edited text doesn't parse the copy is kept
+// around so nothing is lost.
+func editStoredSnippet(dataStore pipetdata.Store, sid string) error {
+	snip, err := dataStore.Read(sid)
+	if err != nil {
+		return errors.Wrap(err, "reading snippet failed")
+	}
 
-	coli := []string{}
-	// compact the output
-	for _, v := range oli {
-		if v != "" {
-			coli = append(coli, v)
-		}
+	buf, err := snip.Marshal()
+	if err != nil {
+		return err
 	}
-	if len(coli) < 2 {
+
+	tmpdir, err := ioutil.TempDir("", "pipet")
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file failed")
+	}
+
+	fn := filepath.Join(tmpdir, filepath.Base(sid))
+	err = ioutil.WriteFile(fn, buf, 0600)
+	if err != nil {
+		os.RemoveAll(tmpdir)
+		return errors.Wrap(err, "creating temporary file failed")
+	}
+
+	err = editSnippet(fn)
+	if err != nil {
+		os.RemoveAll(tmpdir)
+		return err
+	}
+
+	edited, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return errors.Wrap(err, "reading edited snippet failed")
+	}
+
+	if bytes.Equal(buf, edited) {
+		os.RemoveAll(tmpdir)
+		return nil
+	}
+
+	ns := &pipetdata.Snippet{}
+	if err := ns.Unmarshal(edited); err != nil {
+		return errors.Wrapf(err, "edited snippet is invalid, your copy is at %s", fn)
+	}
+	ns.Meta.UID = snip.Meta.UID
+	ns.Touch()
+
+	err = dataStore.Write(ns)
+	if err != nil {
+		return errors.Wrapf(err, "saving snippet failed, your copy is at %s", fn)
+	}
+	return os.RemoveAll(tmpdir)
+}
+
+// parseOutput picks the uid out of the line chosen in fzf
+func parseOutput(out string) (string, error) {
+	out = strings.TrimSuffix(out, "\n")
+	i := strings.Index(out, "\t")
+	if i < 1 {
 		return "", errors.New("bad data")
 	}
-	return coli[len(coli)-1], nil
+	return out[:i], nil
 }
 
 func which(c string) (string, error) {
@@ -163,19 +242,99 @@ func which(c string) (string, error) {
 	return strings.TrimSuffix(w.String(), "\n"), nil
 }
 
+// finderOptions are the picker features a command asks for
+type finderOptions struct {
+	multi bool
+	// preview shows the snippet under the cursor, ids must be its uids
+	preview pipetdata.Store
+}
+
 // basic bare bones wrapper that calls fzf
-// calls fzf on searchText and returns the selected line
-func fuzzyWrapper(searchText string) (sid string, e error) {
+// calls fzf on searchText, lines of uid<tab>text, and returns the uid of the
+// selected line. Only the text is shown.
+func fuzzyWrapper(searchText string, opts finderOptions) (sid string, e error) {
+	ids, err := runFinder(searchText, opts)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// fuzzyMultiWrapper is fuzzyWrapper letting the user select several lines with
+// tab.
+func fuzzyMultiWrapper(searchText string, opts finderOptions) ([]string, error) {
+	opts.multi = true
+	return runFinder(searchText, opts)
+}
+
+// runFinder picks from lines of id<tab>text with fzf, or with the built-in
+// finder if fzf isn't installed or `finder: builtin` is configured.
+func runFinder(searchText string, opts finderOptions) ([]string, error) {
+	builtin := false
+	switch f := viper.GetString("finder"); f {
+	case "builtin":
+		builtin = true
+	case "", "auto":
+		_, err := which("fzf")
+		builtin = err != nil
+	case "fzf":
+	default:
+		return nil, fmt.Errorf("unknown finder %q, use auto, fzf or builtin", f)
+	}
+
+	pc, err := getPreviewConfig()
+	if err != nil {
+		return nil, err
+	}
+	preview := opts.preview != nil && !pc.hidden
+
+	if builtin {
+		fopts := finder.Options{Multi: opts.multi}
+		if preview {
+			fopts.Preview = pc.finderPreview(opts.preview)
+		}
+		return runBuiltinFinder(searchText, fopts)
+	}
+
+	args := []string{}
+	if opts.multi {
+		args = append(args, "--multi")
+	}
+	if preview {
+		pargs, err := pc.fzfArgs()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, pargs...)
+	}
+	return runFzf(searchText, args...)
+}
+
+// runBuiltinFinder is runFzf without fzf
+func runBuiltinFinder(searchText string, opts finder.Options) ([]string, error) {
+	items := []finder.Item{}
+	for _, line := range strings.Split(strings.TrimSuffix(searchText, "\n"), "\n") {
+		id, err := parseOutput(line)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, finder.Item{ID: id, Text: line[len(id)+1:]})
+	}
+	return finder.Find(items, opts)
+}
 
+// runFzf runs fzf with extra args on lines of id<tab>text and returns the ids
+// of the selected lines.
+func runFzf(searchText string, args ...string) ([]string, error) {
 	fzf, err := which("fzf")
 	if err != nil {
-		e = err
-		return
+		return nil, errors.New("fzf is not in path, make sure its installed or set finder: builtin")
 	}
 
 	var w bytes.Buffer
 
-	cmd := exec.Command(fzf)
+	args = append([]string{"--delimiter", "\t", "--with-nth", "2.."}, args...)
+	cmd := exec.Command(fzf, args...)
 
 	cmd.Stdin = strings.NewReader(searchText)
 	cmd.Stdout = &w
@@ -183,21 +342,36 @@ func fuzzyWrapper(searchText string) (sid string, e error) {
 
 	err = cmd.Start()
 	if err != nil {
-		e = errors.Wrap(err, "launching editer failed")
-		return
+		return nil, errors.Wrap(err, "launching editer failed")
 	}
 
 	err = cmd.Wait()
 	if err != nil {
-		e = errors.Wrap(err, "editing failed")
-		return
+		return nil, errors.Wrap(err, "editing failed")
 	}
-	return parseOutput(w.String())
+
+	ids := []string{}
+	for _, line := range strings.SplitAfter(w.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		id, err := parseOutput(line)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("nothing selected")
+	}
+	return ids, nil
 }
 
+// stdin is shared so buffered input isn't lost between readLine calls
+var stdin = bufio.NewReader(os.Stdin)
+
 func readLine() string {
-	reader := bufio.NewReader(os.Stdin)
-	text, err := reader.ReadString('\n')
+	text, err := stdin.ReadString('\n')
 	errorGuard(err, "reading failed")
 
 	return strings.TrimSuffix(text, "\n")
@@ -210,12 +384,13 @@ func renderSnippetList(sns []*pipetdata.Snippet, header bool) string {
 	}
 
 	for _, snip := range sns {
+		title := snip.Meta.Title
 		tags := strings.Join(snip.Meta.Tags, ",")
 		if header {
-			snip.Meta.Title = Green(snip.Meta.Title)
+			title = Green(title)
 			tags = Blue(tags)
 		}
-		out := fmt.Sprintf("%s | %s | %s", snip.Meta.Title,
+		out := fmt.Sprintf("%s | %s | %s", title,
 			tags, snip.Meta.UID)
 		output = append(output, out)
 	}
@@ -223,20 +398,242 @@ func renderSnippetList(sns []*pipetdata.Snippet, header bool) string {
 	return columnize.SimpleFormat(output)
 }
 
+// pickSnippet lets the user choose one of sns with fzf. Lines look like list
+// output unless templates.picker is set in the config.
+func pickSnippet(dataStore pipetdata.Store, sns []*pipetdata.Snippet) (string, error) {
+	searchText, err := pickerText(dataStore, sns)
+	if err != nil {
+		return "", err
+	}
+	return fuzzyWrapper(searchText, finderOptions{preview: dataStore})
+}
+
+// pickSnippets is pickSnippet letting the user select several snippets
+func pickSnippets(dataStore pipetdata.Store, sns []*pipetdata.Snippet) ([]string, error) {
+	searchText, err := pickerText(dataStore, sns)
+	if err != nil {
+		return nil, err
+	}
+	return fuzzyMultiWrapper(searchText, finderOptions{preview: dataStore})
+}
+
+// pickerText is the lines of uid<tab>text offered in the picker
+func pickerText(dataStore pipetdata.Store, sns []*pipetdata.Snippet) (string, error) {
+	var lines []string
+	if _, ok := namedTemplate("picker"); ok {
+		tmpl, err := parseFormat("picker")
+		if err != nil {
+			return "", err
+		}
+		for _, s := range sns {
+			line, err := renderTemplate(tmpl, dataStore, s)
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, strings.Replace(strings.TrimRight(line, "\n"), "\n", " ", -1))
+		}
+	} else {
+		lines = strings.Split(renderSnippetList(sns, false), "\n")
+	}
+
+	searchText := ""
+	for i, s := range sns {
+		searchText += s.Meta.UID + "\t" + lines[i] + "\n"
+	}
+	return searchText, nil
+}
+
 func searchFullSnippet() (sid string, e error) {
+	return searchSnippets(false)
+}
+
+// listSnippets lists the store, or the trash if archived is set, narrowed
+// down by the filter flags. Snippets that can't be read are reported on
+// stderr instead of failing the command.
+func listSnippets(dataStore pipetdata.Store, archived bool) ([]*pipetdata.Snippet, error) {
+	list := dataStore.List
+	if archived {
+		list = dataStore.Archived
+	}
+
+	sns, err := skipBroken(list())
+	if err != nil {
+		return sns, err
+	}
+	return filterSnippets(dataStore, sns)
+}
+
+// candidates lists the snippets to pick from, in picker order
+func candidates(dataStore pipetdata.Store, archived bool) ([]*pipetdata.Snippet, error) {
+	sns, err := listSnippets(dataStore, archived)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing dataStore failed")
+	}
+	if len(sns) == 0 && filtered() {
+		return nil, errors.New("no snippets match")
+	}
+	if len(sns) == 0 {
+		return nil, errors.New("no snippets to pick from")
+	}
+	return sns, sortPicker(sns)
+}
+
+// skipBroken reports snippets a store couldn't read on stderr, it only fails if
+// nothing was readable.
+func skipBroken(sns []*pipetdata.Snippet, err error) ([]*pipetdata.Snippet, error) {
+	if lerr, ok := err.(*pipetdata.ListError); ok {
+		for id, e := range lerr.Errs {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", Red("skipping broken snippet"), id, e)
+		}
+		fmt.Fprintf(os.Stderr, "run `pipet doctor` to fix\n")
+		if len(sns) == 0 {
+			return sns, errors.New("no readable snippets")
+		}
+		err = nil
+	}
+	return sns, err
+}
+
+// searchSnippets lets the user pick a snippet from the store, or from the trash
+// if archived is set.
+func searchSnippets(archived bool) (sid string, e error) {
 	dataStore := getDataStore()
 
-	sns, err := dataStore.List()
+	sns, err := candidates(dataStore, archived)
 	if err != nil {
-		e = errors.Wrap(err, "listing dataStore failed")
-		return
+		return "", err
+	}
+	// filtering down to one snippet picks it, so commands can be scripted
+	if len(sns) == 1 && filtered() {
+		return sns[0].Meta.UID, nil
 	}
 
-	rendered := renderSnippetList(sns, false)
-	sid, err = fuzzyWrapper(rendered)
+	sid, err = pickSnippet(dataStore, sns)
 	if err != nil {
 		e = errors.Wrap(err, "searching failed")
 		return
 	}
 	return sid, err
 }
+
+// snippetArgs returns the uids given as arguments, or lets the user pick any
+// number of snippets from the store, or the trash if archived is set.
+func snippetArgs(dataStore pipetdata.Store, args []string, archived bool) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+
+	sns, err := candidates(dataStore, archived)
+	if err != nil {
+		return nil, err
+	}
+	if len(sns) == 1 && filtered() {
+		return []string{sns[0].Meta.UID}, nil
+	}
+
+	sids, err := pickSnippets(dataStore, sns)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching failed")
+	}
+	return sids, nil
+}
+
+// readSnippets reads every snippet in sids
+func readSnippets(dataStore pipetdata.Store, sids []string) ([]*pipetdata.Snippet, error) {
+	sns := []*pipetdata.Snippet{}
+	for _, sid := range sids {
+		snip, err := dataStore.Read(sid)
+		if err != nil {
+			return nil, errors.Wrap(err, sid)
+		}
+		sns = append(sns, snip)
+	}
+	return sns, nil
+}
+
+// confirm asks a yes or no question, anything but y or yes is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/n]: ", question)
+	answer := readLine()
+	return answer == "y" || answer == "yes"
+}
+
+var (
+	outputFormat = ""
+	withBody     = false
+)
+
+// addOutputFlags adds --output to a command printing snippets, and --with-body
+// if the body is optional for it.
+func addOutputFlags(cmd *cobra.Command, body bool) {
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "",
+		"print "+strings.Join(pipetdata.OutputFormats, "|")+" instead of text")
+	if body {
+		cmd.Flags().BoolVar(&withBody, "with-body", false, "include snippet bodies in --output")
+	}
+}
+
+// checkOutputFlags is a PreRunE for commands taking both --output and --format
+func checkOutputFlags(cmd *cobra.Command, args []string) error {
+	if outputFormat != "" && formatSpec != "" {
+		return errors.New("use either --output or --format")
+	}
+	return ensureConfig(cmd, args)
+}
+
+// printSnippets writes snippets in the --output format, reading bodies first
+// if --with-body is set.
+func printSnippets(dataStore pipetdata.Store, sns []*pipetdata.Snippet) {
+	if withBody {
+		var err error
+		sns, err = skipBroken(readBodies(dataStore, sns))
+		errorGuard(err, "reading snippets failed")
+	}
+	errorGuard(pipetdata.WriteRecords(os.Stdout, outputFormat, sns, withBody), "writing output failed")
+}
+
+// printSnippet shows a single snippet, as a list of one record in the --output
+// format or through the --format or configured show template if one is set.
+func printSnippet(dataStore pipetdata.Store, snip *pipetdata.Snippet) {
+	if outputFormat != "" {
+		errorGuard(pipetdata.WriteRecords(os.Stdout, outputFormat, []*pipetdata.Snippet{snip}, true), "writing output failed")
+		return
+	}
+
+	tmpl, err := formatOrDefault("show")
+	errorGuard(err, "formatting failed")
+	if tmpl == nil {
+		fmt.Print(fancySnippet(snip))
+		return
+	}
+
+	out, err := renderTemplate(tmpl, dataStore, snip)
+	errorGuard(err, "formatting failed")
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	fmt.Print(out)
+}
+
+// readBodies re-reads listed snippets whose Data the store left out.
+func readBodies(dataStore pipetdata.Store, sns []*pipetdata.Snippet) ([]*pipetdata.Snippet, error) {
+	full := []*pipetdata.Snippet{}
+	lerr := &pipetdata.ListError{Errs: map[string]error{}}
+	for _, sn := range sns {
+		if sn.Data != "" {
+			full = append(full, sn)
+			continue
+		}
+		f, err := dataStore.Read(sn.Meta.UID)
+		if err != nil {
+			lerr.Errs[sn.Meta.UID] = err
+			continue
+		}
+		full = append(full, f)
+	}
+
+	if len(lerr.Errs) > 0 {
+		return full, lerr
+	}
+	return full, nil
+}
@@ -0,0 +1,93 @@
+// Copyright © 2018 Dhananjay Balan <mail@dbalan.in>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+//    may be used to endorse or promote products derived from this software
+//    without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dbalan/pipet/pipetdata"
+)
+
+// importVSCodeCmd represents the import vscode command
+var importVSCodeCmd = &cobra.Command{
+	Use:   "vscode file",
+	Short: "Import snippets from a VS Code .code-snippets file",
+	Long: `Imports snippets from a VS Code snippets file, either a global
+.code-snippets file or a per language one like go.json. Names become titles and
+scope languages tags. Tab stops like $1 or ${2:default} are stored as the text
+they insert, the original body is kept in the vscode_body metadata and written
+back by export vscode as long as the snippet isn't edited.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(expandHome(args[0]))
+		errorGuard(err, "opening snippets file failed")
+		defer f.Close()
+
+		sns, err := pipetdata.ReadVSCode(f)
+		errorGuard(err, "reading VS Code snippets failed")
+
+		importSnippets(sns)
+	},
+}
+
+// exportVSCodeCmd represents the export vscode command
+var exportVSCodeCmd = &cobra.Command{
+	Use:   "vscode [uid...]",
+	Short: "Write snippets as a VS Code .code-snippets file",
+	Long: `Writes every live snippet as a VS Code snippets file. The prefix and
+description metadata are used if set, otherwise both come from the title. The
+scope comes from the scope or language metadata, or from tags naming a
+language (bash, go, python, ...).`,
+	Args:    cobra.ArbitraryArgs,
+	PreRunE: ensureConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataStore := getDataStore()
+		sns, err := skipBroken(pipetdata.ReadAll(dataStore))
+		errorGuard(err, "reading store failed")
+		sns = exportSelection(dataStore, sns, args)
+
+		withExportFile(func(w io.Writer) error {
+			return pipetdata.WriteVSCode(w, sns)
+		})
+		if exportOut != "" {
+			fmt.Printf("exported %d snippets to %s\n", len(sns), Green(exportOut))
+		}
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importVSCodeCmd)
+	exportCmd.AddCommand(exportVSCodeCmd)
+}
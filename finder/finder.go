@@ -0,0 +1,396 @@
+// Package finder is a small terminal fuzzy finder, used to pick snippets when
+// fzf is not installed.
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Item is a line to pick from, Text is shown and searched and ID is returned
+// when the line is picked.
+type Item struct {
+	ID   string
+	Text string
+}
+
+// Options changes how the finder behaves
+type Options struct {
+	// Multi lets tab select several items
+	Multi bool
+	// Prompt is shown before the query, "> " if empty
+	Prompt string
+	// Preview, if set, shows the item under the cursor next to the list
+	Preview *Preview
+}
+
+// ErrAborted is returned when the user leaves the finder without picking
+var ErrAborted = errors.New("nothing selected")
+
+// Find shows items on the terminal and lets the user narrow them down by
+// typing. It returns the ids of the picked items, one unless Multi is set.
+func Find(items []Item, opts Options) ([]string, error) {
+	t, err := openTTY()
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	p := newPicker(items, opts)
+	buf := make([]byte, 256)
+	var pending []byte
+	for {
+		rows, cols := t.size()
+		t.Write([]byte(p.render(rows, cols)))
+
+		n, err := t.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var keys []key
+		keys, pending = parseKeys(append(pending, buf[:n]...))
+		for _, k := range keys {
+			if done := p.handle(k); done {
+				return p.result()
+			}
+		}
+	}
+}
+
+// keys the finder understands, printable characters are keyRune
+const (
+	keyNone = iota
+	keyRune
+	keyEnter
+	keyCancel
+	keyBackspace
+	keyClearLine
+	keyDeleteWord
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyTab
+	keyShiftTab
+)
+
+type key struct {
+	code int
+	r    rune
+}
+
+// escape sequences sent for special keys, xterm and vt100 styles
+var escapes = map[string]int{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+	"\x1b[Z":  keyShiftTab,
+}
+
+// parseKeys decodes terminal input, an incomplete character at the end is
+// returned to be completed by the next read.
+func parseKeys(b []byte) ([]key, []byte) {
+	keys := []key{}
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			if len(b) == 1 {
+				// a lone escape is the escape key
+				keys = append(keys, key{code: keyCancel})
+				b = b[1:]
+				continue
+			}
+			n := escapeLen(b)
+			keys = append(keys, key{code: escapes[string(b[:n])]})
+			b = b[n:]
+			continue
+		case c == '\r':
+			keys = append(keys, key{code: keyEnter})
+		case c == 0x03 || c == 0x07 || c == 0x04: // ^C ^G ^D
+			keys = append(keys, key{code: keyCancel})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case c == 0x15: // ^U
+			keys = append(keys, key{code: keyClearLine})
+		case c == 0x17: // ^W
+			keys = append(keys, key{code: keyDeleteWord})
+		case c == 0x10 || c == 0x0b: // ^P ^K
+			keys = append(keys, key{code: keyUp})
+		case c == 0x0e || c == 0x0a: // ^N ^J
+			keys = append(keys, key{code: keyDown})
+		case c == '\t':
+			keys = append(keys, key{code: keyTab})
+		case c < 0x20:
+			// other control characters are ignored
+		default:
+			if !utf8.FullRune(b) {
+				return keys, b
+			}
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, key{code: keyRune, r: r})
+			b = b[n:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys, nil
+}
+
+// escapeLen is the length of the escape sequence at the start of b
+func escapeLen(b []byte) int {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		// alt+key, taken as escape followed by the key
+		return 1
+	}
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// picker is the state of the finder, kept apart from the terminal
+type picker struct {
+	items    []Item
+	opts     Options
+	query    []rune
+	matches  []match
+	cursor   int // into matches
+	offset   int // first match shown
+	selected map[int]bool
+	aborted  bool
+	height   int // items shown on the last render
+
+	previewID   string // item the preview was made for
+	previewText string
+}
+
+func newPicker(items []Item, opts Options) *picker {
+	if opts.Prompt == "" {
+		opts.Prompt = "> "
+	}
+	p := &picker{items: items, opts: opts, selected: map[int]bool{}, height: 1}
+	p.refilter()
+	return p
+}
+
+func (p *picker) refilter() {
+	p.matches = filter(p.items, string(p.query))
+	p.cursor, p.offset = 0, 0
+}
+
+func (p *picker) move(by int) {
+	p.cursor += by
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.height {
+		p.offset = p.cursor - p.height + 1
+	}
+}
+
+// handle applies a key, it returns true when the finder is done
+func (p *picker) handle(k key) bool {
+	switch k.code {
+	case keyRune:
+		p.query = append(p.query, k.r)
+		p.refilter()
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.refilter()
+		}
+	case keyClearLine:
+		p.query = nil
+		p.refilter()
+	case keyDeleteWord:
+		q := strings.TrimRight(string(p.query), " ")
+		if i := strings.LastIndex(q, " "); i >= 0 {
+			q = q[:i+1]
+		} else {
+			q = ""
+		}
+		p.query = []rune(q)
+		p.refilter()
+	case keyUp:
+		p.move(-1)
+	case keyDown:
+		p.move(1)
+	case keyPageUp:
+		p.move(-p.height)
+	case keyPageDown:
+		p.move(p.height)
+	case keyTab, keyShiftTab:
+		if p.opts.Multi && len(p.matches) > 0 {
+			i := p.matches[p.cursor].index
+			p.selected[i] = !p.selected[i]
+			if !p.selected[i] {
+				delete(p.selected, i)
+			}
+			if k.code == keyTab {
+				p.move(1)
+			} else {
+				p.move(-1)
+			}
+		}
+	case keyEnter:
+		return len(p.matches) > 0 || len(p.selected) > 0
+	case keyCancel:
+		p.aborted = true
+		return true
+	}
+	return false
+}
+
+// result is the ids of the selected items in their original order, or the
+// one under the cursor if none are selected.
+func (p *picker) result() ([]string, error) {
+	if p.aborted {
+		return nil, ErrAborted
+	}
+
+	ids := []string{}
+	for i, it := range p.items {
+		if p.selected[i] {
+			ids = append(ids, it.ID)
+		}
+	}
+	if len(ids) == 0 && len(p.matches) > 0 {
+		ids = append(ids, p.items[p.matches[p.cursor].index].ID)
+	}
+	if len(ids) == 0 {
+		return nil, ErrAborted
+	}
+	return ids, nil
+}
+
+// terminal control sequences
+const (
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+	bold        = "\x1b[1m"
+	highlight   = "\x1b[32m"
+	dim         = "\x1b[2m"
+	reset       = "\x1b[0m"
+	setPosition = "\x1b[%d;%dH"
+)
+
+// rect is an area of the screen, top and left count from 1
+type rect struct {
+	top, left, rows, cols int
+}
+
+// render draws the prompt, a line of counts, as many items as fit and the
+// preview, and leaves the cursor after the query. Every line is padded to
+// its full width so nothing of the last frame is left.
+func (p *picker) render(rows, cols int) string {
+	list, preview := p.layout(rows, cols)
+	p.height = list.rows - 2
+	if p.height < 1 {
+		p.height = 1
+	}
+	p.move(0)
+
+	var b strings.Builder
+	b.WriteString(hideCursor)
+	b.WriteString(drawLines(list, p.listLines(list.cols)))
+	if preview.rows > 0 {
+		b.WriteString(p.renderPreview(preview))
+	}
+
+	col := utf8.RuneCountInString(p.opts.Prompt) + len(p.query)
+	if col >= list.cols {
+		col = list.cols - 1
+	}
+	b.WriteString(fmt.Sprintf(setPosition, list.top, list.left+col) + showCursor)
+	return b.String()
+}
+
+// listLines is the prompt, counts and visible items cut to width
+func (p *picker) listLines(width int) []string {
+	info := fmt.Sprintf("  %d/%d", len(p.matches), len(p.items))
+	if len(p.selected) > 0 {
+		info += fmt.Sprintf(" (%d)", len(p.selected))
+	}
+	lines := []string{
+		truncate(p.opts.Prompt+string(p.query), width),
+		dim + truncate(info, width) + reset,
+	}
+
+	for i := p.offset; i < len(p.matches) && i < p.offset+p.height; i++ {
+		m := p.matches[i]
+		cur, sel := "  ", " "
+		if i == p.cursor {
+			cur = bold + "> "
+		}
+		if p.selected[m.index] {
+			sel = "*"
+		}
+		lines = append(lines, cur+sel+highlightText(p.items[m.index].Text, m.pos, width-3, i == p.cursor))
+	}
+	return lines
+}
+
+// drawLines puts lines in r, padding each to the width of r and blanking the
+// rows below them.
+func drawLines(r rect, lines []string) string {
+	var b strings.Builder
+	for i := 0; i < r.rows; i++ {
+		line := ""
+		if i < len(lines) {
+			line = lines[i]
+		}
+		b.WriteString(fmt.Sprintf(setPosition, r.top+i, r.left))
+		b.WriteString(line + reset + strings.Repeat(" ", r.cols-visibleWidth(line)))
+	}
+	return b.String()
+}
+
+// highlightText colours the matched runes of text, cut to width
+func highlightText(text string, pos []int, width int, current bool) string {
+	var b strings.Builder
+	after := reset
+	if current {
+		after += bold
+	}
+
+	k := 0
+	for i, r := range []rune(text) {
+		if i >= width {
+			break
+		}
+		for k < len(pos) && pos[k] < i {
+			k++
+		}
+		if k < len(pos) && pos[k] == i {
+			b.WriteString(highlight + string(r) + after)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package finder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys, rest := parseKeys([]byte("ab\x1b[A\x1b[B\r\x7f\x15\x17\t\x1b[Z\x03"))
+	assert.Nil(t, rest, "everything is consumed")
+	codes := []int{}
+	for _, k := range keys {
+		codes = append(codes, k.code)
+	}
+	assert.Equal(t, []int{keyRune, keyRune, keyUp, keyDown, keyEnter, keyBackspace,
+		keyClearLine, keyDeleteWord, keyTab, keyShiftTab, keyCancel}, codes, "keys are decoded")
+	assert.Equal(t, 'a', keys[0].r, "rune is kept")
+
+	keys, _ = parseKeys([]byte("\x1b"))
+	assert.Equal(t, []key{{code: keyCancel}}, keys, "lone escape cancels")
+
+	keys, rest = parseKeys([]byte("é")[:1])
+	assert.Empty(t, keys, "half a character is not a key")
+	assert.Equal(t, []byte("é")[:1], rest, "it is kept for the next read")
+	keys, _ = parseKeys(append(rest, []byte("é")[1:]...))
+	assert.Equal(t, []key{{code: keyRune, r: 'é'}}, keys, "completed character")
+}
+
+// typeKeys feeds input to the picker until it is done
+func typeKeys(p *picker, input string) bool {
+	keys, _ := parseKeys([]byte(input))
+	for _, k := range keys {
+		if p.handle(k) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPicker(t *testing.T) {
+	items := []Item{
+		{"a.txt", "tar extract archive"},
+		{"b.txt", "list docker containers"},
+		{"c.txt", "docker logs"},
+		{"d.txt", "delete old kernels"},
+	}
+
+	p := newPicker(items, Options{})
+	assert.True(t, typeKeys(p, "\r"), "enter picks")
+	ids, err := p.result()
+	assert.Nil(t, err, "something is picked")
+	assert.Equal(t, []string{"a.txt"}, ids, "first item without a query")
+
+	p = newPicker(items, Options{})
+	assert.True(t, typeKeys(p, "dockr\x7fer\x1b[B\r"), "typing narrows the list")
+	ids, _ = p.result()
+	assert.Equal(t, []string{"b.txt"}, ids, "second best docker match")
+
+	p = newPicker(items, Options{})
+	assert.False(t, typeKeys(p, "zzz\r"), "enter does nothing without matches")
+	assert.True(t, typeKeys(p, "\x15\x1b[B\x1b[B\x1b[B\x1b[B\x1b[B\r"), "cursor stops at the end")
+	ids, _ = p.result()
+	assert.Equal(t, []string{"d.txt"}, ids, "last item")
+
+	p = newPicker(items, Options{})
+	assert.True(t, typeKeys(p, "dock\x03"), "ctrl-c leaves")
+	_, err = p.result()
+	assert.Equal(t, ErrAborted, err, "nothing picked")
+
+	p = newPicker(items, Options{})
+	typeKeys(p, "\t")
+	typeKeys(p, "docker log\x17\x17")
+	assert.Equal(t, "", string(p.query), "ctrl-w deletes words")
+	typeKeys(p, "\x1b[B\x1b[B\t")
+	ids, _ = p.result()
+	assert.Equal(t, []string{"c.txt"}, ids, "tab only selects with Multi, the cursor is picked")
+
+	p = newPicker(items, Options{Multi: true})
+	assert.True(t, typeKeys(p, "\t\t\x1b[Z\t\r"), "tab selects")
+	ids, _ = p.result()
+	assert.Equal(t, []string{"a.txt", "c.txt"}, ids, "selection in item order, toggled off again")
+}
+
+func TestRender(t *testing.T) {
+	items := []Item{}
+	for _, s := range strings.Split("one two three four five six seven eight nine ten", " ") {
+		items = append(items, Item{ID: s, Text: s})
+	}
+	p := newPicker(items, Options{})
+	typeKeys(p, "e")
+
+	screen := p.render(5, 20)
+	assert.Contains(t, screen, "> e", "prompt shows the query")
+	assert.Contains(t, screen, "7/10", "counts are shown")
+	assert.Contains(t, screen, "ight", "word starts rank first")
+	assert.Contains(t, screen, "on", "matches are listed")
+	assert.NotContains(t, screen, "fiv", "only what fits is drawn")
+
+	for i := 0; i < 6; i++ {
+		p.handle(key{code: keyDown})
+	}
+	assert.Equal(t, 6, p.cursor, "cursor moved")
+	assert.Equal(t, 4, p.offset, "list scrolled to keep the cursor visible")
+	assert.Contains(t, p.render(5, 20), highlight+"e", "matches are highlighted")
+}
+
+func TestPreview(t *testing.T) {
+	items := []Item{{"a", "first"}, {"b", "second"}}
+	asked := 0
+	pv := &Preview{Text: func(id string) string {
+		asked++
+		return "\x1b[32mbody of " + id + "\x1b[0m\nline two is long\n"
+	}}
+	p := newPicker(items, Options{Preview: pv})
+
+	list, preview := p.layout(10, 40)
+	assert.Equal(t, rect{1, 1, 10, 20}, list, "list on the left")
+	assert.Equal(t, rect{1, 21, 10, 20}, preview, "preview on the right by default")
+
+	pv.Position, pv.Size = "up", 30
+	list, preview = p.layout(10, 40)
+	assert.Equal(t, rect{4, 1, 7, 40}, list, "list below")
+	assert.Equal(t, rect{1, 1, 3, 40}, preview, "preview above")
+
+	pv.Position = "left"
+	list, preview = p.layout(10, 12)
+	assert.Equal(t, rect{1, 1, 10, 12}, list, "no preview when the list would be too narrow")
+	assert.Equal(t, rect{}, preview, "no preview")
+
+	pv.Position, pv.Size = "right", 50
+	screen := p.render(10, 40)
+	assert.Contains(t, screen, "body of a", "preview of the current item")
+	p.render(10, 40)
+	assert.Equal(t, 1, asked, "preview is cached")
+	p.handle(key{code: keyDown})
+	assert.Contains(t, p.render(10, 40), "body of b", "preview follows the cursor")
+
+	assert.Equal(t, []string{"line", " two", " is ", "long"}, previewLines("line two is long", 4, true), "wrapped")
+	assert.Equal(t, []string{"\x1b[32mline"}, previewLines("\x1b[32mline two", 4, false), "cut, escapes take no room")
+	assert.Equal(t, 4, visibleWidth("\x1b[1mline\x1b[0m"), "escapes are not counted")
+}
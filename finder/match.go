@@ -0,0 +1,142 @@
+package finder
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// scoring, loosely after fzf: every matched character scores, matches at the
+// start of words and runs of consecutive characters score more, gaps cost.
+const (
+	scoreMatch        = 16
+	bonusBoundary     = 10
+	bonusCamel        = 8
+	bonusConsecutive  = 8
+	penaltyGap        = 1
+	maxGapPenaltyTerm = 32
+)
+
+// match is an item that passed the query
+type match struct {
+	index int   // into the items
+	score int   // higher is better
+	pos   []int // rune offsets of matched characters, sorted
+}
+
+// terms splits a query into space separated terms, all of which must match.
+func terms(query string) [][]rune {
+	ts := [][]rune{}
+	for _, f := range strings.Fields(query) {
+		ts = append(ts, []rune(f))
+	}
+	return ts
+}
+
+// smart case: a query with upper case letters is case sensitive
+func caseSensitive(query string) bool {
+	return strings.IndexFunc(query, unicode.IsUpper) >= 0
+}
+
+// matchTerm finds term as a subsequence of text. The first occurrence is found
+// going forward and then shrunk going back from its end, so the matched window
+// is as tight as possible.
+func matchTerm(text, term []rune, sensitive bool) (int, []int, bool) {
+	eq := func(a, b rune) bool {
+		if sensitive {
+			return a == b
+		}
+		return unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	j, end := 0, -1
+	for i := 0; i < len(text) && j < len(term); i++ {
+		if eq(text[i], term[j]) {
+			j++
+			if j == len(term) {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	pos := make([]int, len(term))
+	j = len(term) - 1
+	for i := end; i >= 0 && j >= 0; i-- {
+		if eq(text[i], term[j]) {
+			pos[j] = i
+			j--
+		}
+	}
+
+	score, gaps := 0, 0
+	for k, p := range pos {
+		score += scoreMatch
+		switch {
+		case p == 0 || !isWord(text[p-1]):
+			score += bonusBoundary
+		case unicode.IsLower(text[p-1]) && unicode.IsUpper(text[p]):
+			score += bonusCamel
+		}
+		if k > 0 {
+			if p == pos[k-1]+1 {
+				score += bonusConsecutive
+			} else {
+				gaps += (p - pos[k-1] - 1) * penaltyGap
+			}
+		}
+	}
+	if gaps > maxGapPenaltyTerm {
+		gaps = maxGapPenaltyTerm
+	}
+	return score - gaps, pos, true
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Score matches text against query, every term of the query has to match. It
+// returns the score and the offsets of the matched runes.
+func Score(text, query string) (int, []int, bool) {
+	sensitive := caseSensitive(query)
+	runes := []rune(text)
+
+	total, pos := 0, []int{}
+	for _, t := range terms(query) {
+		s, p, ok := matchTerm(runes, t, sensitive)
+		if !ok {
+			return 0, nil, false
+		}
+		total += s
+		pos = append(pos, p...)
+	}
+	sort.Ints(pos)
+	return total, pos, true
+}
+
+// filter returns the items matching query, best first. Ties go to the
+// shorter text, then to the order of the items. An empty query matches
+// everything in order.
+func filter(items []Item, query string) []match {
+	ms := []match{}
+	for i, it := range items {
+		if s, pos, ok := Score(it.Text, query); ok {
+			ms = append(ms, match{index: i, score: s, pos: pos})
+		}
+	}
+
+	if len(terms(query)) == 0 {
+		// nothing typed yet, keep the order we were given
+		return ms
+	}
+	sort.SliceStable(ms, func(i, j int) bool {
+		if ms[i].score != ms[j].score {
+			return ms[i].score > ms[j].score
+		}
+		return len(items[ms[i].index].Text) < len(items[ms[j].index].Text)
+	})
+	return ms
+}
@@ -0,0 +1,65 @@
+package finder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	_, pos, ok := Score("docker logs", "dlg")
+	assert.True(t, ok, "subsequence should match")
+	assert.Equal(t, []int{0, 7, 9}, pos, "matched runes")
+
+	_, _, ok = Score("docker logs", "gld")
+	assert.False(t, ok, "order matters within a term")
+
+	_, pos, ok = Score("docker logs", "logs dock")
+	assert.True(t, ok, "terms match in any order")
+	assert.Equal(t, []int{0, 1, 2, 3, 7, 8, 9, 10}, pos, "positions of every term")
+
+	_, _, ok = Score("docker logs", "logs kube")
+	assert.False(t, ok, "every term has to match")
+
+	_, _, ok = Score("Docker logs", "docker")
+	assert.True(t, ok, "lower case query ignores case")
+	_, _, ok = Score("docker logs", "Docker")
+	assert.False(t, ok, "upper case makes the query case sensitive")
+
+	_, pos, _ = Score("aab", "ab")
+	assert.Equal(t, []int{1, 2}, pos, "the match is shrunk from its end")
+
+	word, _, _ := Score("git log", "log")
+	inner, _, _ := Score("catalogue", "log")
+	assert.True(t, word > inner, "word starts score higher")
+
+	tight, _, _ := Score("kubectl", "kub")
+	loose, _, _ := Score("kxuxb", "kub")
+	assert.True(t, tight > loose, "consecutive matches score higher")
+
+	camel, _, _ := Score("getLogs", "l")
+	plain, _, _ := Score("getalogs", "l")
+	assert.True(t, camel > plain, "camel case humps count as word starts")
+}
+
+func TestFilter(t *testing.T) {
+	items := []Item{
+		{"1", "tar extract archive"},
+		{"2", "list docker containers"},
+		{"3", "docker"},
+		{"4", "delete old kernels"},
+	}
+
+	ids := func(ms []match) []string {
+		l := []string{}
+		for _, m := range ms {
+			l = append(l, items[m.index].ID)
+		}
+		return l
+	}
+
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids(filter(items, "")), "empty query keeps the order")
+	assert.Equal(t, []string{"3", "2"}, ids(filter(items, "docker")), "shorter text wins a tie")
+	assert.Equal(t, []string{"4"}, ids(filter(items, "del ker")), "every term must match")
+	assert.Empty(t, filter(items, "zzz"), "nothing matches")
+}
@@ -0,0 +1,179 @@
+package finder
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Preview describes the pane showing the item under the cursor
+type Preview struct {
+	// Text returns what to show for the item with the id, it may be coloured
+	// with ANSI escapes.
+	Text func(id string) string
+	// Position of the pane: right (the default), left, up or down
+	Position string
+	// Size of the pane in percent of the screen, half if zero
+	Size int
+	// Wrap long lines instead of cutting them
+	Wrap bool
+}
+
+// PreviewPositions are the places a preview can be shown
+var PreviewPositions = []string{"right", "left", "up", "down"}
+
+// layout splits the screen between the list and the preview, the preview is
+// dropped if either would get too small.
+func (p *picker) layout(rows, cols int) (list, preview rect) {
+	list = rect{1, 1, rows, cols}
+	pv := p.opts.Preview
+	if pv == nil || pv.Text == nil {
+		return list, rect{}
+	}
+
+	size := pv.Size
+	if size <= 0 || size >= 100 {
+		size = 50
+	}
+
+	switch pv.Position {
+	case "up", "down":
+		n := rows * size / 100
+		if n < 3 || rows-n < 3 {
+			return list, rect{}
+		}
+		preview = rect{1, 1, n, cols}
+		list.rows = rows - n
+		if pv.Position == "up" {
+			list.top = n + 1
+		} else {
+			preview.top = rows - n + 1
+		}
+	default:
+		n := cols * size / 100
+		if n < 4 || cols-n < 10 {
+			return list, rect{}
+		}
+		preview = rect{1, 1, rows, n}
+		list.cols = cols - n
+		if pv.Position == "left" {
+			list.left = n + 1
+		} else {
+			preview.left = cols - n + 1
+		}
+	}
+	return list, preview
+}
+
+// renderPreview draws a border on the side facing the list and the preview
+// of the current item inside it.
+func (p *picker) renderPreview(r rect) string {
+	var b strings.Builder
+	inner := r
+
+	switch p.opts.Preview.Position {
+	case "up":
+		inner.rows--
+		b.WriteString(fmt.Sprintf(setPosition, r.top+inner.rows, r.left))
+		b.WriteString(dim + strings.Repeat("─", r.cols) + reset)
+	case "down":
+		inner.top++
+		inner.rows--
+		b.WriteString(fmt.Sprintf(setPosition, r.top, r.left))
+		b.WriteString(dim + strings.Repeat("─", r.cols) + reset)
+	case "left":
+		inner.cols -= 2
+		for i := 0; i < r.rows; i++ {
+			b.WriteString(fmt.Sprintf(setPosition, r.top+i, r.left+inner.cols))
+			b.WriteString(" " + dim + "│" + reset)
+		}
+	default:
+		inner.left += 2
+		inner.cols -= 2
+		for i := 0; i < r.rows; i++ {
+			b.WriteString(fmt.Sprintf(setPosition, r.top+i, r.left))
+			b.WriteString(dim + "│" + reset + " ")
+		}
+	}
+
+	lines := previewLines(p.preview(), inner.cols, p.opts.Preview.Wrap)
+	b.WriteString(drawLines(inner, lines))
+	return b.String()
+}
+
+// preview is the text for the item under the cursor, it is only asked for
+// again when the cursor moves to another item.
+func (p *picker) preview() string {
+	if len(p.matches) == 0 {
+		return ""
+	}
+	id := p.items[p.matches[p.cursor].index].ID
+	if id != p.previewID {
+		p.previewID = id
+		p.previewText = p.opts.Preview.Text(id)
+	}
+	return p.previewText
+}
+
+// previewLines splits text into lines no wider than width, wrapping or
+// cutting the long ones.
+func previewLines(text string, width int, wrap bool) []string {
+	text = strings.Replace(text, "\t", "    ", -1)
+	text = strings.Replace(text, "\r", "", -1)
+
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		head, tail := cutVisible(line, width)
+		lines = append(lines, head)
+		for wrap && visibleWidth(tail) > 0 {
+			head, tail = cutVisible(tail, width)
+			lines = append(lines, head)
+		}
+	}
+	return lines
+}
+
+// cutVisible splits s after width visible runes, escape sequences don't take
+// up any room and stay with the head.
+func cutVisible(s string, width int) (string, string) {
+	w := 0
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		if w == width {
+			return s[:i], s[i:]
+		}
+		_, n := utf8.DecodeRuneInString(s[i:])
+		i += n
+		w++
+	}
+	return s, ""
+}
+
+// visibleWidth is the number of runes of s shown on the terminal
+func visibleWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		_, n := utf8.DecodeRuneInString(s[i:])
+		i += n
+		w++
+	}
+	return w
+}
+
+// ansiLen is the length of the escape sequence s starts with, if any
+func ansiLen(s string) int {
+	if s[0] != 0x1b {
+		return 0
+	}
+	if n := escapeLen([]byte(s)); n > 1 {
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package finder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// terminal switches stdin and stdout in and out of the alternate screen
+const (
+	enterScreen = "\x1b[?1049h"
+	leaveScreen = "\x1b[?1049l"
+)
+
+// tty is the controlling terminal in raw mode, it is used even when stdin or
+// stdout are redirected. Modes are changed with stty so no terminal library
+// is needed.
+type tty struct {
+	*os.File
+	state string
+}
+
+func openTTY() (*tty, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening terminal failed")
+	}
+
+	t := &tty{File: f}
+	t.state, err = t.stty("-g")
+	if err == nil {
+		_, err = t.stty("raw", "-echo")
+	}
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "setting up terminal failed")
+	}
+
+	fmt.Fprint(f, enterScreen)
+	return t, nil
+}
+
+func (t *tty) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.File
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// size is the terminal's rows and columns, 24x80 if it can't be found
+func (t *tty) size() (int, int) {
+	var rows, cols int
+	out, err := t.stty("size")
+	if err != nil {
+		return 24, 80
+	}
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil || rows == 0 || cols == 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+func (t *tty) close() {
+	fmt.Fprint(t.File, leaveScreen)
+	t.stty(t.state)
+	t.File.Close()
+}
@@ -0,0 +1,237 @@
+package pipetdata
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Attachment is a file kept along with a snippet, like the config a script
+// reads or the entrypoint of a Dockerfile.
+type Attachment struct {
+	Name string
+	Mode os.FileMode
+	Data []byte
+}
+
+// Attacher is implemented by stores that can keep attachments with a snippet.
+// Read and List return the names of a snippet's attachments in Meta.Files,
+// sorted. Writing a snippet leaves its attachments alone.
+type Attacher interface {
+	// Attach adds a file to a live snippet, replacing one with the same name.
+	Attach(id string, a *Attachment) error
+	// Detach removes a file from a live snippet.
+	Detach(id, name string) error
+	// Attachment returns a file of a snippet, archived or not.
+	Attachment(id, name string) (*Attachment, error)
+}
+
+// ReadAttachments returns every attachment of the snippet
+func ReadAttachments(s Store, id string) ([]*Attachment, error) {
+	a, ok := s.(Attacher)
+	if !ok {
+		return nil, nil
+	}
+
+	sn, err := s.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*Attachment{}
+	for _, name := range sn.Meta.Files {
+		f, err := a.Attachment(id, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s failed", name)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// manifestName is the file holding metadata and text of a directory snippet.
+// A snippet with attachments is a directory named by its uid, like
+//
+//	<uid>/snippet.txt
+//	<uid>/run.sh
+//	<uid>/config.yaml
+//
+// in place of the <uid> file. Snippets turn into directories when something
+// is attached to them.
+const manifestName = "snippet.txt"
+
+func validAttachmentName(name string) bool {
+	return name != "" && filepath.Base(name) == name && name[0] != '.' && name != manifestName
+}
+
+func badAttachmentName(name string) error {
+	return fmt.Errorf("invalid file name %q, it can't contain / or start with . or be %s", name, manifestName)
+}
+
+// snippetFile is the file holding the snippet at path, which may be a
+// directory snippet.
+func snippetFile(path string) string {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return filepath.Join(path, manifestName)
+	}
+	return path
+}
+
+func isDirSnippet(path string) bool {
+	return snippetFile(path) != path
+}
+
+// dirFiles lists the attachments of a directory snippet
+func dirFiles(dir string) ([]string, error) {
+	fli, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range fli {
+		if !f.IsDir() && validAttachmentName(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// dirInfo is the FileInfo of a directory snippet: the manifest's, but with
+// the directory's mtime if that is newer since adding or removing a file
+// only changes the directory.
+type dirInfo struct {
+	os.FileInfo
+	mod time.Time
+}
+
+func (i dirInfo) ModTime() time.Time { return i.mod }
+
+// statSnippet stats the snippet at path, fi is what ReadDir returned for it.
+func statSnippet(path string, fi os.FileInfo) (os.FileInfo, error) {
+	if !fi.IsDir() {
+		return fi, nil
+	}
+
+	mfi, err := os.Stat(filepath.Join(path, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	if fi.ModTime().After(mfi.ModTime()) {
+		return dirInfo{mfi, fi.ModTime()}, nil
+	}
+	return mfi, nil
+}
+
+// Attach adds a file to the snippet, turning it into a directory snippet.
+func (d *DataStore) Attach(id string, a *Attachment) error {
+	return d.locked(func() error {
+		return d.attach(id, a)
+	})
+}
+
+func (d *DataStore) attach(id string, a *Attachment) error {
+	if !validAttachmentName(a.Name) {
+		return badAttachmentName(a.Name)
+	}
+	if !d.Exist(id) {
+		return errors.New("no such document")
+	}
+
+	if err := d.makeDir(id); err != nil {
+		return errors.Wrap(err, "converting to a directory snippet failed")
+	}
+
+	mode := a.Mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := writeFileAtomic(filepath.Join(d.path(id), a.Name), a.Data, mode); err != nil {
+		return err
+	}
+	return d.touch(id)
+}
+
+// makeDir moves a plain snippet file to the manifest of a directory snippet.
+func (d *DataStore) makeDir(id string) error {
+	path := d.path(id)
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return err
+	}
+
+	tmp := filepath.Join(d.documentDir, tmpPrefix+id)
+	if err := os.Rename(path, tmp); err != nil {
+		return err
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		os.Rename(tmp, path)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(path, manifestName)); err != nil {
+		os.Remove(path)
+		os.Rename(tmp, path)
+		return err
+	}
+	return nil
+}
+
+// Detach removes a file from the snippet.
+func (d *DataStore) Detach(id, name string) error {
+	return d.locked(func() error {
+		return d.detach(id, name)
+	})
+}
+
+func (d *DataStore) detach(id, name string) error {
+	if !validAttachmentName(name) {
+		return badAttachmentName(name)
+	}
+	if !d.Exist(id) {
+		return errors.New("no such document")
+	}
+
+	if err := os.Remove(filepath.Join(d.path(id), name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no file %s in snippet", name)
+		}
+		return err
+	}
+	return d.touch(id)
+}
+
+// touch marks the snippet as changed after its files changed
+func (d *DataStore) touch(id string) error {
+	s, err := readSnippet(d.path(id))
+	if err != nil {
+		return err
+	}
+	s.Touch()
+	return d.write(s)
+}
+
+// Attachment reads a file of the snippet, live or archived.
+func (d *DataStore) Attachment(id, name string) (*Attachment, error) {
+	if !validAttachmentName(name) || !validID(id) {
+		return nil, fmt.Errorf("no file %s in snippet", name)
+	}
+
+	for _, dir := range []string{d.path(id), d.trashpath(id)} {
+		fn := filepath.Join(dir, name)
+		fi, err := os.Stat(fn)
+		if err != nil {
+			continue
+		}
+		buf, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &Attachment{Name: name, Mode: fi.Mode().Perm(), Data: buf}, nil
+	}
+	return nil, fmt.Errorf("no file %s in snippet", name)
+}
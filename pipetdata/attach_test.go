@@ -0,0 +1,153 @@
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testAttacher exercises attachments, every backend keeping files should pass.
+func testAttacher(t *testing.T, s Store) {
+	a, ok := s.(Attacher)
+	assert.True(t, ok, "store should keep attachments")
+
+	uid, err := s.New("Deploy", "ops")
+	assert.Nil(t, err, "new snippet must be created")
+	sn, _ := s.Read(uid)
+	sn.Data = "./run.sh\n"
+	assert.Nil(t, s.Write(sn), "write should succeed")
+
+	for _, name := range []string{"", "../escape", ".hidden", manifestName} {
+		assert.NotNil(t, a.Attach(uid, &Attachment{Name: name}), "%q is not a valid name", name)
+	}
+	assert.NotNil(t, a.Attach("nosuch.txt", &Attachment{Name: "run.sh"}), "no such snippet")
+
+	assert.Nil(t, a.Attach(uid, &Attachment{Name: "run.sh", Mode: 0755, Data: []byte("echo old\n")}), "attach")
+	assert.Nil(t, a.Attach(uid, &Attachment{Name: "run.sh", Mode: 0755, Data: []byte("echo hi\n")}), "replace")
+	assert.Nil(t, a.Attach(uid, &Attachment{Name: "config.yaml", Data: []byte("env: prod\n")}), "attach")
+
+	sn, err = s.Read(uid)
+	assert.Nil(t, err, "snippet with files can be read")
+	assert.Equal(t, "./run.sh\n", sn.Data, "text is kept")
+	assert.Equal(t, []string{"config.yaml", "run.sh"}, sn.Meta.Files, "files are listed by name")
+	assert.True(t, sn.Meta.Updated.After(*sn.Meta.Created) || sn.Meta.Updated.Equal(*sn.Meta.Created),
+		"attaching updates the snippet")
+
+	sn.Data = "./run.sh --verbose\n"
+	assert.Nil(t, s.Write(sn), "write should succeed")
+	sns, err := s.List()
+	assert.Nil(t, err, "list should succeed")
+	assert.Len(t, sns, 1, "one snippet")
+	assert.Equal(t, []string{"config.yaml", "run.sh"}, sns[0].Meta.Files, "writing keeps the files")
+
+	f, err := a.Attachment(uid, "run.sh")
+	assert.Nil(t, err, "attachment can be read")
+	assert.Equal(t, "echo hi\n", string(f.Data), "latest content")
+	assert.Equal(t, os.FileMode(0755), f.Mode, "mode is kept")
+	f, err = a.Attachment(uid, "config.yaml")
+	assert.Nil(t, err, "attachment can be read")
+	assert.Equal(t, os.FileMode(0644), f.Mode, "default mode")
+
+	files, err := ReadAttachments(s, uid)
+	assert.Nil(t, err, "reading all attachments")
+	assert.Len(t, files, 2, "two files")
+
+	assert.Nil(t, a.Detach(uid, "config.yaml"), "detach")
+	assert.NotNil(t, a.Detach(uid, "config.yaml"), "second detach should fail")
+	_, err = a.Attachment(uid, "config.yaml")
+	assert.NotNil(t, err, "detached file is gone")
+
+	assert.Nil(t, s.Archive(uid), "archive should succeed")
+	sn, err = s.Read(uid)
+	assert.Nil(t, err, "archived snippet can be read")
+	assert.Equal(t, []string{"run.sh"}, sn.Meta.Files, "archived snippet keeps its files")
+	_, err = a.Attachment(uid, "run.sh")
+	assert.Nil(t, err, "files of archived snippets can be read")
+	assert.NotNil(t, a.Attach(uid, &Attachment{Name: "x.sh"}), "archived snippets can't change")
+
+	assert.Nil(t, s.Restore(uid), "restore should succeed")
+	sns, err = s.List()
+	assert.Nil(t, err, "list should succeed")
+	assert.Equal(t, []string{"run.sh"}, sns[0].Meta.Files, "restored snippet has its files")
+
+	assert.Nil(t, s.Delete(uid), "delete should succeed")
+	_, err = a.Attachment(uid, "run.sh")
+	assert.NotNil(t, err, "files go with the snippet")
+}
+
+func TestMemStoreAttach(t *testing.T) {
+	testAttacher(t, NewMemStore())
+}
+
+func TestDirStoreAttach(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+	testAttacher(t, ds)
+
+	uid, err := ds.New("Deploy", "ops")
+	assert.Nil(t, err, "new snippet must be created")
+	assert.Nil(t, ds.Attach(uid, &Attachment{Name: "run.sh", Data: []byte("echo hi\n")}), "attach")
+
+	fi, err := os.Stat(filepath.Join(tmpdir, uid))
+	assert.Nil(t, err, "snippet still lives at its uid")
+	assert.True(t, fi.IsDir(), "snippet turned into a directory")
+	assert.Equal(t, filepath.Join(tmpdir, uid, manifestName), ds.Fullpath(uid), "path of the manifest")
+	_, err = os.Stat(filepath.Join(tmpdir, uid, "run.sh"))
+	assert.Nil(t, err, "file is kept next to the manifest")
+
+	problems, err := ds.Check()
+	assert.Nil(t, err, "check should succeed")
+	assert.Empty(t, problems, "directory snippets are fine")
+
+	assert.Nil(t, os.Mkdir(filepath.Join(tmpdir, "empty.txt"), 0755), "stray directory")
+	problems, err = ds.Check()
+	assert.Nil(t, err, "check should succeed")
+	assert.Len(t, problems, 1, "directory without manifest")
+	assert.Equal(t, StrayFile, problems[0].Kind, "it is not a snippet")
+
+	// the index is rebuilt from directories too
+	assert.Nil(t, os.Remove(filepath.Join(tmpdir, indexFileName)), "dropping the index")
+	sns, err := ds.List()
+	assert.Nil(t, err, "list should succeed")
+	assert.Len(t, sns, 1, "directory snippet is listed")
+	assert.Equal(t, []string{"run.sh"}, sns[0].Meta.Files, "files are listed")
+}
+
+func TestCopyStoreAttachments(t *testing.T) {
+	src := NewMemStore()
+	uid, err := src.New("Deploy", "ops")
+	assert.Nil(t, err, "new snippet must be created")
+	assert.Nil(t, src.Attach(uid, &Attachment{Name: "run.sh", Mode: 0755, Data: []byte("echo hi\n")}), "attach")
+	assert.Nil(t, src.Archive(uid), "archive should succeed")
+	live, err := src.New("Backup", "ops")
+	assert.Nil(t, err, "new snippet must be created")
+	assert.Nil(t, src.Attach(live, &Attachment{Name: "backup.sh", Data: []byte("tar c .\n")}), "attach")
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	n, err := CopyStore(ds, src)
+	assert.Nil(t, err, "copy should succeed")
+	assert.Equal(t, 2, n, "both snippets copied")
+
+	for _, id := range []string{uid, live} {
+		orig, _ := src.Read(id)
+		copied, err := ds.Read(id)
+		assert.Nil(t, err, "copied snippet can be read")
+		assert.Equal(t, orig.Meta.Files, copied.Meta.Files, "files are copied")
+		assert.Equal(t, orig.Meta.Archived == nil, copied.Meta.Archived == nil, "trash is copied")
+		assert.True(t, orig.Meta.Updated.Equal(*copied.Meta.Updated), "timestamps are kept")
+	}
+
+	f, err := ds.Attachment(uid, "run.sh")
+	assert.Nil(t, err, "attachment is copied")
+	assert.Equal(t, "echo hi\n", string(f.Data), "content is copied")
+}
@@ -0,0 +1,304 @@
+package pipetdata
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// bundleVersion is bumped when the layout of bundles changes
+const bundleVersion = 1
+
+// bundleDir holds the snippet files inside a tar bundle
+const bundleDir = "snippets"
+
+// jsonBundle is the layout of a json bundle
+type jsonBundle struct {
+	Version  int      `json:"version"`
+	Snippets []Record `json:"snippets"`
+}
+
+// Dump returns every snippet in s, the trash included, with its body. Snippets
+// that can't be read are left out and reported in a *ListError.
+func Dump(s Store) ([]*Snippet, error) {
+	sns, err := ReadAll(s)
+	lerr, ok := err.(*ListError)
+	if err != nil && !ok {
+		return sns, err
+	}
+
+	archived, err := s.Archived()
+	if e, ok := err.(*ListError); ok {
+		if lerr == nil {
+			lerr = e
+		} else {
+			for id, err := range e.Errs {
+				lerr.Errs[id] = err
+			}
+		}
+	} else if err != nil {
+		return sns, err
+	}
+	sns = append(sns, archived...)
+
+	sort.Slice(sns, func(i, j int) bool {
+		return sns[i].Meta.UID < sns[j].Meta.UID
+	})
+	if lerr != nil {
+		return sns, lerr
+	}
+	return sns, nil
+}
+
+// WriteBundle writes sns to w as a gzipped tarball of snippet files, or as a
+// single json document if format is "json". Bundles don't carry attachments,
+// snippets with files are refused rather than exported without them.
+func WriteBundle(w io.Writer, format string, sns []*Snippet) error {
+	withFiles := []string{}
+	for _, s := range sns {
+		if len(s.Meta.Files) > 0 {
+			withFiles = append(withFiles, s.Meta.UID)
+		}
+	}
+	if len(withFiles) > 0 {
+		return fmt.Errorf("bundles can't hold attached files, leave out %s", strings.Join(withFiles, ", "))
+	}
+
+	switch format {
+	case "tar":
+		return writeTarBundle(w, sns)
+	case "json":
+		b := jsonBundle{Version: bundleVersion, Snippets: []Record{}}
+		for _, s := range sns {
+			b.Snippets = append(b.Snippets, NewRecord(s, true))
+		}
+		return writeJSON(w, b)
+	}
+	return fmt.Errorf("unknown bundle format %q, use tar or json", format)
+}
+
+func writeTarBundle(w io.Writer, sns []*Snippet) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, s := range sns {
+		data, err := s.Marshal()
+		if err != nil {
+			return errors.Wrapf(err, "rendering %s failed", s.Meta.UID)
+		}
+
+		mtime := time.Now()
+		if s.Meta.Updated != nil {
+			mtime = *s.Meta.Updated
+		}
+		hdr := &tar.Header{
+			Name:    path.Join(bundleDir, s.Meta.UID),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: mtime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// ReadBundle reads a bundle written by WriteBundle, either format.
+func ReadBundle(r io.Reader) ([]*Snippet, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return readTarBundle(br)
+	}
+
+	var b jsonBundle
+	if err := json.NewDecoder(br).Decode(&b); err != nil {
+		return nil, errors.Wrap(err, "not a pipet bundle")
+	}
+	if b.Version > bundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than this pipet understands", b.Version)
+	}
+
+	sns := []*Snippet{}
+	for _, r := range b.Snippets {
+		sns = append(sns, r.Snippet())
+	}
+	return sns, nil
+}
+
+func readTarBundle(r io.Reader) ([]*Snippet, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a pipet bundle")
+	}
+	tr := tar.NewReader(gr)
+
+	sns := []*Snippet{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading bundle failed")
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Dir(hdr.Name) != bundleDir {
+			continue
+		}
+
+		buf, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading bundle failed")
+		}
+		s := &Snippet{}
+		if err := s.Unmarshal(buf); err != nil {
+			return nil, errors.Wrapf(err, "bad snippet %s in bundle", hdr.Name)
+		}
+		sns = append(sns, s)
+	}
+	return sns, nil
+}
+
+// Collision tells Import what to do with a snippet whose uid is already taken
+type Collision int
+
+// Ways of handling uid collisions
+const (
+	Skip      Collision = iota // keep the existing snippet
+	Overwrite                  // replace it
+	Rename                     // import under a new uid
+)
+
+// Collisions are the names ParseCollision accepts, by value
+var Collisions = []string{"skip", "overwrite", "rename"}
+
+func (c Collision) String() string {
+	return Collisions[c]
+}
+
+// ParseCollision parses the name of a collision strategy
+func ParseCollision(name string) (Collision, error) {
+	for i, n := range Collisions {
+		if n == name {
+			return Collision(i), nil
+		}
+	}
+	return Skip, fmt.Errorf("unknown collision strategy %q, use one of %s", name, strings.Join(Collisions, ", "))
+}
+
+// Import actions
+const (
+	Added     = "add"
+	Unchanged = "unchanged"
+	Skipped   = "skip"
+	Replaced  = "overwrite"
+	Renamed   = "rename"
+)
+
+// ImportAction describes what Import did, or would do, with one snippet
+type ImportAction struct {
+	UID    string // uid in the store
+	From   string // uid in the bundle, if renamed
+	Title  string
+	Action string
+}
+
+// Import writes sns into dst. Snippets without a uid get a fresh one,
+// snippets whose uid is taken are handled as on says, identical copies are
+// left alone. With dryRun nothing is written, the returned actions tell what
+// would have happened.
+func Import(dst Store, sns []*Snippet, on Collision, dryRun bool) ([]ImportAction, error) {
+	actions := []ImportAction{}
+	for _, s := range sns {
+		s = copySnippet(s)
+		a := ImportAction{UID: s.Meta.UID, Title: s.Meta.Title, Action: Added}
+
+		var existing *Snippet
+		if s.Meta.UID == "" {
+			s.Meta.UID = newID()
+			a.UID = s.Meta.UID
+		} else if e, err := dst.Read(s.Meta.UID); err == nil {
+			existing = e
+		}
+
+		if existing != nil {
+			switch {
+			case sameSnippet(existing, s):
+				a.Action = Unchanged
+			case on == Skip:
+				a.Action = Skipped
+			case on == Overwrite:
+				a.Action = Replaced
+				// keep when it was first created, unless the import says
+				if s.Meta.Created == nil {
+					s.Meta.Created = existing.Meta.Created
+				}
+			case on == Rename:
+				a.Action, a.From = Renamed, s.Meta.UID
+				s.Meta.UID = newID()
+				a.UID = s.Meta.UID
+				existing = nil
+			}
+		}
+		actions = append(actions, a)
+
+		if dryRun || a.Action == Unchanged || a.Action == Skipped {
+			continue
+		}
+
+		// a snippet moving in or out of the trash would otherwise exist twice
+		if existing != nil && (existing.Meta.Archived == nil) != (s.Meta.Archived == nil) {
+			if err := dst.Delete(existing.Meta.UID); err != nil {
+				return actions, errors.Wrapf(err, "replacing %s failed", a.UID)
+			}
+		}
+		if err := dst.Write(s); err != nil {
+			return actions, errors.Wrapf(err, "importing %s failed", a.UID)
+		}
+	}
+	return actions, nil
+}
+
+// sameSnippet compares content, timestamps and format version are left out
+// since stores fill them in on write. So is the order and formatting of extra
+// fields, which not every format keeps.
+func sameSnippet(a, b *Snippet) bool {
+	content := func(s *Snippet) ([]byte, error) {
+		c := copySnippet(s)
+		c.Meta.Created, c.Meta.Updated, c.Meta.FormatVersion = nil, nil, 0
+		if c.Meta.Archived != nil {
+			c.Meta.Archived = &time.Time{}
+		}
+		c.Meta.raw = nil
+		sort.Slice(c.Meta.Extra, func(i, j int) bool {
+			return fmt.Sprint(c.Meta.Extra[i].Key) < fmt.Sprint(c.Meta.Extra[j].Key)
+		})
+		return c.Marshal()
+	}
+
+	x, err := content(a)
+	if err != nil {
+		return false
+	}
+	y, err := content(b)
+	return err == nil && bytes.Equal(x, y)
+}
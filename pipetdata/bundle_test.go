@@ -0,0 +1,129 @@
+package pipetdata
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bundleStore(t *testing.T) *MemStore {
+	s := NewMemStore()
+	uid, err := s.New("Kernel version", "linux")
+	assert.Nil(t, err, "new snippet")
+	sn, _ := s.Read(uid)
+	sn.Data = "uname -a\n"
+	assert.Nil(t, sn.SetField("source", map[interface{}]interface{}{"url": "https://wiki"}), "extra field")
+	assert.Nil(t, sn.SetField("ticket", 12), "extra field")
+	assert.Nil(t, s.Write(sn), "write")
+
+	old, err := s.New("Uptime")
+	assert.Nil(t, err, "new snippet")
+	assert.Nil(t, s.Archive(old), "archive")
+	return s
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	src := bundleStore(t)
+	sns, err := Dump(src)
+	assert.Nil(t, err, "dump")
+	assert.Len(t, sns, 2, "live and archived snippets")
+
+	for _, format := range []string{"tar", "json"} {
+		var buf bytes.Buffer
+		assert.Nil(t, WriteBundle(&buf, format, sns), "writing %s bundle", format)
+
+		read, err := ReadBundle(&buf)
+		assert.Nil(t, err, "reading %s bundle", format)
+		assert.Len(t, read, 2, "%s bundle has every snippet", format)
+
+		dst := NewMemStore()
+		actions, err := Import(dst, read, Skip, false)
+		assert.Nil(t, err, "import %s bundle", format)
+		assert.Len(t, actions, 2, "two actions")
+
+		for _, s := range sns {
+			got, err := dst.Read(s.Meta.UID)
+			assert.Nil(t, err, "%s imported from %s bundle", s.Meta.UID, format)
+			want, _ := s.Marshal()
+			have, _ := got.Marshal()
+			assert.Equal(t, string(want), string(have), "%s bundle round trips", format)
+		}
+
+		archived, _ := dst.Archived()
+		assert.Len(t, archived, 1, "trash is kept in %s bundle", format)
+	}
+
+	assert.NotNil(t, WriteBundle(&bytes.Buffer{}, "zip", sns), "unknown format")
+	live, _ := src.List()
+	assert.Nil(t, src.Attach(live[0].Meta.UID, &Attachment{Name: "run.sh", Data: []byte("uname -a\n")}), "attach")
+	sns, _ = Dump(src)
+	for _, format := range []string{"tar", "json"} {
+		err := WriteBundle(&bytes.Buffer{}, format, sns)
+		assert.NotNil(t, err, "%s bundle refuses attachments", format)
+	}
+	_, err = ReadBundle(bytes.NewBufferString("hello"))
+	assert.NotNil(t, err, "not a bundle")
+}
+
+func TestImportCollisions(t *testing.T) {
+	dst := bundleStore(t)
+	sns, _ := Dump(dst)
+	live := sns[0]
+	if live.Meta.Archived != nil {
+		live = sns[1]
+	}
+
+	changed := copySnippet(live)
+	changed.Data = "uname -r\n"
+	fresh := &Snippet{Meta: metadata{Title: "No uid"}, Data: "true\n"}
+	in := []*Snippet{changed, fresh}
+
+	actions, err := Import(dst, in, Rename, true)
+	assert.Nil(t, err, "dry run")
+	assert.Equal(t, Renamed, actions[0].Action, "collision is renamed")
+	assert.Equal(t, live.Meta.UID, actions[0].From, "original uid is reported")
+	assert.Equal(t, Added, actions[1].Action, "snippet without uid is added")
+	all, _ := Dump(dst)
+	assert.Len(t, all, 2, "dry run writes nothing")
+
+	actions, err = Import(dst, in[:1], Skip, false)
+	assert.Nil(t, err, "skip")
+	assert.Equal(t, Skipped, actions[0].Action, "collision is skipped")
+	got, _ := dst.Read(live.Meta.UID)
+	assert.Equal(t, "uname -a\n", got.Data, "existing snippet is kept")
+
+	actions, err = Import(dst, in[:1], Overwrite, false)
+	assert.Nil(t, err, "overwrite")
+	assert.Equal(t, Replaced, actions[0].Action, "collision is overwritten")
+	got, _ = dst.Read(live.Meta.UID)
+	assert.Equal(t, "uname -r\n", got.Data, "existing snippet is replaced")
+
+	bare := copySnippet(changed)
+	bare.Meta.Created, bare.Data = nil, "uname -s\n"
+	_, err = Import(dst, []*Snippet{bare}, Overwrite, false)
+	assert.Nil(t, err, "overwrite")
+	got, _ = dst.Read(live.Meta.UID)
+	assert.Equal(t, live.Meta.Created, got.Meta.Created, "created time is kept")
+	_, err = Import(dst, in[:1], Overwrite, false)
+	assert.Nil(t, err, "overwrite")
+
+	actions, err = Import(dst, in[:1], Skip, false)
+	assert.Nil(t, err, "unchanged")
+	assert.Equal(t, Unchanged, actions[0].Action, "identical snippet is left alone")
+
+	changed.Data = "uname -m\n"
+	actions, err = Import(dst, in, Rename, false)
+	assert.Nil(t, err, "rename")
+	assert.NotEqual(t, live.Meta.UID, actions[0].UID, "renamed snippet gets a new uid")
+	got, _ = dst.Read(actions[0].UID)
+	assert.Equal(t, "uname -m\n", got.Data, "renamed snippet is imported")
+	all, _ = Dump(dst)
+	assert.Len(t, all, 4, "renamed and new snippet added")
+
+	_, err = ParseCollision("merge")
+	assert.NotNil(t, err, "unknown strategy")
+	c, err := ParseCollision("overwrite")
+	assert.Nil(t, err, "known strategy")
+	assert.Equal(t, Overwrite, c, "parsed strategy")
+}
@@ -0,0 +1,230 @@
+package pipetdata
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// quarantineDir holds files doctor couldn't repair, relative to documentDir
+const quarantineDir = ".quarantine"
+
+// ProblemKind tells what is wrong with a file in the store
+type ProblemKind int
+
+// Problems found by Check
+const (
+	MissingFrontMatter ProblemKind = iota // no --- delimited metadata
+	BadMetadata                           // metadata is not valid yaml
+	UIDMismatch                           // uid in metadata differs from file name
+	DuplicateUID                          // another file claims the same uid
+	StrayFile                             // not a snippet at all
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case MissingFrontMatter:
+		return "missing front matter"
+	case BadMetadata:
+		return "bad metadata"
+	case UIDMismatch:
+		return "uid mismatch"
+	case DuplicateUID:
+		return "duplicate uid"
+	case StrayFile:
+		return "stray file"
+	}
+	return "unknown problem"
+}
+
+// Problem is an issue with a single file, File is relative to the store root.
+type Problem struct {
+	Kind   ProblemKind
+	File   string
+	Detail string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s (%s)", p.File, p.Kind, p.Detail)
+}
+
+// Fixable reports if Repair can do something about the problem.
+func (p Problem) Fixable() bool {
+	return p.Kind != StrayFile || strings.HasPrefix(filepath.Base(p.File), tmpPrefix)
+}
+
+// Checker is implemented by stores that can check and repair their storage.
+type Checker interface {
+	// Check scans the store and returns every problem found.
+	Check() ([]Problem, error)
+	// Repair fixes a problem returned by Check.
+	Repair(p Problem) error
+}
+
+// Check looks for snippet files that List can't read or that disagree with
+// their file name.
+func (d *DataStore) Check() ([]Problem, error) {
+	problems := []Problem{}
+	owners := map[string][]string{}
+
+	for _, dir := range []string{".", trashDir} {
+		fli, err := ioutil.ReadDir(filepath.Join(d.documentDir, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range fli {
+			name := filepath.Join(dir, f.Name())
+			switch {
+			case strings.HasPrefix(f.Name(), tmpPrefix):
+				problems = append(problems, Problem{StrayFile, name, "left over from an interrupted write"})
+			case strings.HasPrefix(f.Name(), "."):
+				// trash, git and other bookkeeping
+			case !validID(f.Name()):
+				problems = append(problems, Problem{StrayFile, name, "not a snippet"})
+			case f.IsDir() && !isDirSnippet(filepath.Join(d.documentDir, name)):
+				problems = append(problems, Problem{StrayFile, name, "directory without " + manifestName})
+			default:
+				p, uid := d.checkFile(name)
+				if p != nil {
+					problems = append(problems, *p)
+				} else {
+					owners[uid] = append(owners[uid], name)
+				}
+			}
+		}
+	}
+
+	for uid, files := range owners {
+		for _, name := range files {
+			if filepath.Base(name) == uid {
+				continue
+			}
+
+			kind, detail := UIDMismatch, fmt.Sprintf("metadata says %q", uid)
+			if len(files) > 1 {
+				kind, detail = DuplicateUID, fmt.Sprintf("%q is claimed by %d files", uid, len(files))
+			}
+			problems = append(problems, Problem{kind, name, detail})
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].File < problems[j].File
+	})
+	return problems, nil
+}
+
+// checkFile parses a single snippet file, or the manifest of a directory
+// snippet, and returns the uid in its metadata.
+func (d *DataStore) checkFile(name string) (*Problem, string) {
+	buf, err := ioutil.ReadFile(snippetFile(filepath.Join(d.documentDir, name)))
+	if err != nil {
+		return &Problem{StrayFile, name, err.Error()}, ""
+	}
+
+	format, front, _, err := splitData(buf)
+	if err != nil {
+		return &Problem{MissingFrontMatter, name, "no metadata block"}, ""
+	}
+
+	meta, err := parseFront(format, front)
+	if err != nil {
+		return &Problem{BadMetadata, name, err.Error()}, ""
+	}
+	return nil, meta.UID
+}
+
+// Repair fixes the problem where possible: front matter is regenerated for
+// plain text files, uids are set from the file name, files with broken
+// metadata are moved to .quarantine and left over temporary files removed.
+func (d *DataStore) Repair(p Problem) error {
+	if !p.Fixable() {
+		return errors.Errorf("%s can't be repaired automatically", p.File)
+	}
+
+	filename := filepath.Join(d.documentDir, p.File)
+	uid := filepath.Base(p.File)
+
+	return d.locked(func() error {
+		switch p.Kind {
+		case StrayFile:
+			return os.Remove(filename)
+
+		case BadMetadata:
+			dest := filepath.Join(d.documentDir, quarantineDir, p.File)
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			return os.Rename(filename, dest)
+
+		case MissingFrontMatter:
+			buf, err := ioutil.ReadFile(snippetFile(filename))
+			if err != nil {
+				return err
+			}
+			s := &Snippet{Meta: metadata{UID: uid, Title: recoveredTitle(buf, uid)}, Data: string(buf)}
+			if err := d.keepArchived(p.File, s); err != nil {
+				return err
+			}
+			return d.rewrite(snippetFile(filename), s)
+
+		case UIDMismatch, DuplicateUID:
+			s, err := readSnippet(filename)
+			if err != nil {
+				return err
+			}
+			s.Meta.UID = uid
+			if err := d.keepArchived(p.File, s); err != nil {
+				return err
+			}
+			return d.rewrite(snippetFile(filename), s)
+		}
+		return nil
+	})
+}
+
+// keepArchived marks a snippet repaired in the trash as archived, when it was
+// put there is unknown so the file's modification time is used.
+func (d *DataStore) keepArchived(name string, s *Snippet) error {
+	if s.Meta.Archived != nil || filepath.Dir(name) != trashDir {
+		return nil
+	}
+	fi, err := os.Stat(filepath.Join(d.documentDir, name))
+	if err != nil {
+		return err
+	}
+	mtime := fi.ModTime()
+	s.Meta.Archived = &mtime
+	return nil
+}
+
+func (d *DataStore) rewrite(filename string, s *Snippet) error {
+	data, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data, 0755)
+}
+
+// recoveredTitle uses the first line of text as title for a snippet missing
+// its metadata.
+func recoveredTitle(buf []byte, uid string) string {
+	line := string(bytes.TrimSpace(bytes.SplitN(bytes.TrimSpace(buf), []byte("\n"), 2)[0]))
+	if line == "" {
+		return "recovered " + uid
+	}
+	if r := []rune(line); len(r) > 60 {
+		line = string(r[:60])
+	}
+	return line
+}
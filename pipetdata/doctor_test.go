@@ -0,0 +1,106 @@
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctor(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	good, err := ds.New("Kernel version", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+
+	files := map[string]string{
+		"plain.txt":        "uname -a\n",
+		"badyaml.txt":      "---\ntitle: [unclosed\n---\nuname -a\n",
+		"mismatch.txt":     "---\nuid: other.txt\ntitle: Mismatch\n---\nuname -a\n",
+		"dup.txt":          "---\nuid: " + good + "\ntitle: Duplicate\n---\nuname -a\n",
+		"notes.md":         "# not a snippet\n",
+		tmpPrefix + "1234": "half written",
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(tmpdir, name), []byte(content), 0644)
+		assert.Nil(t, err, "writing test file")
+	}
+
+	// broken files don't stop listing
+	sns, err := ds.List()
+	assert.IsType(t, &ListError{}, err, "list should report broken files")
+	assert.Len(t, err.(*ListError).Errs, 2, "two files can't be read")
+	assert.Len(t, sns, 3, "readable snippets are still listed")
+
+	problems, err := ds.Check()
+	assert.Nil(t, err, "check should succeed")
+
+	kinds := map[string]ProblemKind{}
+	for _, p := range problems {
+		kinds[p.File] = p.Kind
+	}
+	assert.Equal(t, map[string]ProblemKind{
+		"plain.txt":        MissingFrontMatter,
+		"badyaml.txt":      BadMetadata,
+		"mismatch.txt":     UIDMismatch,
+		"dup.txt":          DuplicateUID,
+		"notes.md":         StrayFile,
+		tmpPrefix + "1234": StrayFile,
+	}, kinds, "every problem should be found")
+
+	for _, p := range problems {
+		if p.Fixable() {
+			assert.Nil(t, ds.Repair(p), "repairing %s", p.File)
+		} else {
+			assert.NotNil(t, ds.Repair(p), "%s is not fixable", p.File)
+		}
+	}
+
+	problems, err = ds.Check()
+	assert.Nil(t, err, "check should succeed")
+	assert.Len(t, problems, 1, "only the stray file is left")
+	assert.Equal(t, "notes.md", problems[0].File, "stray files are left alone")
+
+	_, err = os.Stat(filepath.Join(tmpdir, quarantineDir, "badyaml.txt"))
+	assert.Nil(t, err, "broken file should be quarantined")
+
+	sn, err := ds.Read("plain.txt")
+	assert.Nil(t, err, "front matter should be regenerated")
+	assert.Equal(t, "uname -a", sn.Meta.Title, "title comes from the first line")
+	assert.Equal(t, "uname -a\n", sn.Data, "content is kept")
+
+	sns, err = ds.List()
+	assert.Nil(t, err, "everything can be listed now")
+	assert.Len(t, sns, 4, "repaired snippets are listed")
+}
+
+func TestDoctorTrash(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(tmpdir, trashDir), 0755), "trash directory")
+	err = ioutil.WriteFile(filepath.Join(tmpdir, trashDir, "plain.txt"), []byte("uname -a\n"), 0644)
+	assert.Nil(t, err, "writing test file")
+
+	problems, err := ds.Check()
+	assert.Nil(t, err, "check should succeed")
+	assert.Len(t, problems, 1, "trash file is checked")
+	assert.Nil(t, ds.Repair(problems[0]), "repairing trash file")
+
+	sns, err := ds.Archived()
+	assert.Nil(t, err, "trash can be listed")
+	assert.Len(t, sns, 1, "repaired snippet is in the trash")
+	assert.NotNil(t, sns[0].Meta.Archived, "repaired snippet has a deletion date")
+
+	assert.Nil(t, ds.Restore("plain.txt"), "repaired snippet can be restored")
+	assert.True(t, ds.Exist("plain.txt"), "snippet should be live again")
+}
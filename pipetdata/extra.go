@@ -0,0 +1,142 @@
+package pipetdata
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// metadataKeys are the front matter keys decoded into metadata's fields
+var metadataKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(metadata{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if f.PkgPath == "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// rawField is an extra front matter key as it was written in the file, it is
+// written back verbatim as long as its value is unchanged. Re-encoding would
+// lose comments and quote things like dates that yaml.v2 decodes as strings.
+type rawField struct {
+	text  string
+	value interface{}
+}
+
+// Field returns the value of an extra front matter key.
+func (s *Snippet) Field(key string) (interface{}, bool) {
+	for _, item := range s.Meta.Extra {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// SetField sets an extra front matter key, keys pipet itself uses can't be
+// set this way. A nil value removes the key.
+func (s *Snippet) SetField(key string, value interface{}) error {
+	if metadataKeys[key] {
+		return fmt.Errorf("%s is not an extra field", key)
+	}
+
+	for i, item := range s.Meta.Extra {
+		if item.Key == key {
+			if value == nil {
+				s.Meta.Extra = append(s.Meta.Extra[:i:i], s.Meta.Extra[i+1:]...)
+			} else {
+				s.Meta.Extra[i].Value = value
+			}
+			return nil
+		}
+	}
+
+	if value != nil {
+		s.Meta.Extra = append(s.Meta.Extra, yaml.MapItem{Key: key, Value: value})
+	}
+	return nil
+}
+
+// extraFields picks the keys metadata doesn't know about out of front matter,
+// along with their original text.
+func extraFields(front []byte) (yaml.MapSlice, map[string]rawField, error) {
+	var all yaml.MapSlice
+	if err := yaml.Unmarshal(front, &all); err != nil {
+		return nil, nil, err
+	}
+
+	var extra yaml.MapSlice
+	for _, item := range all {
+		if key, ok := item.Key.(string); !ok || !metadataKeys[key] {
+			extra = append(extra, item)
+		}
+	}
+	if len(extra) == 0 {
+		return nil, nil, nil
+	}
+
+	raw := map[string]rawField{}
+	for _, block := range topLevelBlocks(string(front)) {
+		var item yaml.MapSlice
+		if yaml.Unmarshal([]byte(block), &item) != nil || len(item) != 1 {
+			continue
+		}
+		if key, ok := item[0].Key.(string); ok && !metadataKeys[key] {
+			raw[key] = rawField{block, item[0].Value}
+		}
+	}
+	return extra, raw, nil
+}
+
+// topLevelBlocks splits yaml text at every line starting a new top level key.
+func topLevelBlocks(text string) []string {
+	blocks := []string{}
+	block := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line != "" && !strings.ContainsAny(line[:1], " \t#-\r\n") && block != "" {
+			blocks = append(blocks, block)
+			block = ""
+		}
+		block += line
+	}
+	if block != "" {
+		blocks = append(blocks, block)
+	}
+
+	for i := range blocks {
+		if !strings.HasSuffix(blocks[i], "\n") {
+			blocks[i] += "\n"
+		}
+	}
+	return blocks
+}
+
+// marshalExtra renders the extra fields, unchanged ones as they were read.
+func (m *metadata) marshalExtra() ([]byte, error) {
+	out := []byte{}
+	for _, item := range m.Extra {
+		if key, ok := item.Key.(string); ok {
+			if r, ok := m.raw[key]; ok && reflect.DeepEqual(r.value, item.Value) {
+				out = append(out, r.text...)
+				continue
+			}
+		}
+
+		buf, err := yaml.Marshal(yaml.MapSlice{item})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, buf...)
+	}
+	return out, nil
+}
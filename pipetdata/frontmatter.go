@@ -0,0 +1,246 @@
+package pipetdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// FrontMatters are the metadata formats snippet files can use, as in hugo:
+//
+//	---          +++                  {
+//	title: x     title = "x"            "title": "x"
+//	---          +++                  }
+//	text         text                 text
+//
+// Files in a directory store are written back in the format they are in, new
+// ones and everything else Marshal writes in the format set with
+// SetFrontMatter, yaml unless changed.
+var FrontMatters = []string{"yaml", "toml", "json"}
+
+var frontMatter = "yaml"
+
+// SetFrontMatter sets the format Marshal and new snippet files use.
+func SetFrontMatter(format string) error {
+	for _, f := range FrontMatters {
+		if f == format {
+			frontMatter = format
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown front matter format %q, valid formats are %s",
+		format, strings.Join(FrontMatters, ", "))
+}
+
+var frontGuards = map[string][]byte{
+	"yaml": []byte("---"),
+	"toml": []byte("+++"),
+}
+
+// sniffFrontMatter tells the front matter format from the start of a file,
+// "" if it has none.
+func sniffFrontMatter(buf []byte) string {
+	if bytes.HasPrefix(buf, []byte("{")) {
+		return "json"
+	}
+	for format, guard := range frontGuards {
+		if bytes.HasPrefix(buf, guard) {
+			return format
+		}
+	}
+	return ""
+}
+
+// fileFrontMatter is the front matter format of the first existing file of
+// fns, the default if none exists.
+func fileFrontMatter(fns ...string) string {
+	for _, fn := range fns {
+		f, err := os.Open(fn)
+		if err != nil {
+			continue
+		}
+		head := make([]byte, 3)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+
+		if format := sniffFrontMatter(head[:n]); format != "" {
+			return format
+		}
+		break
+	}
+	return frontMatter
+}
+
+// splitData separates the front matter from the text and says which format
+// it is in.
+func splitData(buf []byte) (format string, front, data []byte, err error) {
+	format = sniffFrontMatter(buf)
+	if format == "" {
+		return "", nil, nil, EBadData
+	}
+	if format == "json" {
+		return splitJSON(buf)
+	}
+
+	guard := frontGuards[format]
+	lg := len(guard)
+	buf = buf[lg:]
+
+	end := bytes.Index(buf, guard)
+	if end == -1 {
+		return "", nil, nil, EBadData
+	}
+
+	front = buf[0:end]
+	// skip guard + '\n'
+	data = bytes.TrimPrefix(buf[end+lg:], []byte("\n"))
+	return format, front, data, nil
+}
+
+// splitJSON splits json front matter, an object at the start of the file.
+func splitJSON(buf []byte) (format string, front, data []byte, err error) {
+	r := bytes.NewReader(buf)
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&json.RawMessage{}); err != nil {
+		return "", nil, nil, EBadData
+	}
+
+	rest, _ := dec.Buffered().(*bytes.Reader)
+	end := len(buf) - r.Len() - rest.Len()
+	return "json", buf[:end], bytes.TrimPrefix(buf[end:], []byte("\n")), nil
+}
+
+// parseFront decodes front matter in any of the formats. toml and json are
+// converted to yaml first, so they are decoded exactly like yaml.
+func parseFront(format string, front []byte) (meta metadata, err error) {
+	if format != "yaml" {
+		front, err = frontYAML(format, front)
+		if err != nil {
+			return meta, errors.Wrap(err, "bad metadata")
+		}
+	}
+
+	if err := yaml.Unmarshal(front, &meta); err != nil {
+		return meta, errors.Wrap(err, "bad metadata")
+	}
+
+	meta.Extra, meta.raw, err = extraFields(front)
+	if err != nil {
+		return meta, errors.Wrap(err, "bad metadata")
+	}
+	return meta, nil
+}
+
+func frontYAML(format string, front []byte) ([]byte, error) {
+	var fields yaml.MapSlice
+	switch format {
+	case "toml":
+		tree, err := toml.LoadBytes(front)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range tomlKeys(tree) {
+			fields = append(fields, yaml.MapItem{Key: key, Value: fromTOML(tree.Get(key))})
+		}
+
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(front))
+		dec.UseNumber()
+		v, err := decodeJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := v.(orderedMap)
+		if !ok {
+			return nil, errors.New("front matter is not an object")
+		}
+		for _, item := range m {
+			value := yamlValue(item.Value)
+			// json has no dates, the times pipet writes are strings
+			if s, ok := value.(string); ok && metadataKeys[fmt.Sprint(item.Key)] {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					value = t
+				}
+			}
+			fields = append(fields, yaml.MapItem{Key: item.Key, Value: value})
+		}
+	}
+	return yaml.Marshal(fields)
+}
+
+// fields lists the front matter keys in the order yaml writes them
+func (m *metadata) fields() yaml.MapSlice {
+	fields := yaml.MapSlice{{Key: "uid", Value: m.UID}, {Key: "title", Value: m.Title}}
+	if len(m.Tags) > 0 {
+		fields = append(fields, yaml.MapItem{Key: "tags", Value: m.Tags})
+	}
+	for _, t := range []struct {
+		key string
+		t   *time.Time
+	}{{"created", m.Created}, {"updated", m.Updated}, {"archived", m.Archived}} {
+		if t.t != nil {
+			fields = append(fields, yaml.MapItem{Key: t.key, Value: *t.t})
+		}
+	}
+	if m.FormatVersion != 0 {
+		fields = append(fields, yaml.MapItem{Key: "format_version", Value: m.FormatVersion})
+	}
+	return append(fields, m.Extra...)
+}
+
+// marshalTOML renders the front matter as toml. Tables have to follow the
+// plain keys, or the keys after them would belong to the table.
+func (m *metadata) marshalTOML() ([]byte, error) {
+	var plain, tables bytes.Buffer
+	for _, item := range m.fields() {
+		if item.Value == nil {
+			continue
+		}
+		value := toTOML(item.Value)
+		tree, err := toml.TreeFromMap(map[string]interface{}{fmt.Sprint(item.Key): value})
+		if err != nil {
+			return nil, err
+		}
+
+		out := &plain
+		if isTable(value) {
+			out = &tables
+		}
+		if _, err := tree.WriteTo(out); err != nil {
+			return nil, err
+		}
+	}
+	return append(plain.Bytes(), tables.Bytes()...), nil
+}
+
+func isTable(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return true
+	case []interface{}:
+		return len(v) > 0 && isTable(v[0])
+	}
+	return false
+}
+
+// marshalJSON renders the front matter as a json object
+func (m *metadata) marshalJSON() ([]byte, error) {
+	fields := orderedMap{}
+	for _, item := range m.fields() {
+		fields = append(fields, yaml.MapItem{Key: item.Key, Value: jsonValue(item.Value)})
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, fields); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
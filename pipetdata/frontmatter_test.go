@@ -0,0 +1,102 @@
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+var tomlSnippet = `+++
+uid = "hugo.txt"
+title = "Restart web"
+tags = ["k8s", "ops"]
+created = 2018-05-01T10:00:00Z
+format_version = 1
+owner = "ops"
+
+[source]
+  url = "https://wiki/restart"
++++
+kubectl rollout restart deployment/web
+`
+
+var jsonSnippet = `{
+  "uid": "hugo.txt",
+  "title": "Restart web",
+  "tags": ["k8s", "ops"],
+  "created": "2018-05-01T10:00:00Z",
+  "format_version": 1,
+  "owner": "ops",
+  "source": {"url": "https://wiki/restart"}
+}
+kubectl rollout restart deployment/web
+`
+
+func TestFrontMatter(t *testing.T) {
+	created := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	extra := yaml.MapSlice{
+		{Key: "owner", Value: "ops"},
+		{Key: "source", Value: yaml.MapSlice{{Key: "url", Value: "https://wiki/restart"}}},
+	}
+
+	for _, text := range []string{tomlSnippet, jsonSnippet} {
+		var s Snippet
+		assert.Nil(t, s.Unmarshal([]byte(text)), "should parse")
+		assert.Equal(t, "Restart web", s.Meta.Title, "title")
+		assert.Equal(t, []string{"k8s", "ops"}, s.Meta.Tags, "tags")
+		assert.True(t, created.Equal(*s.Meta.Created), "created")
+		assert.Equal(t, 1, s.Meta.FormatVersion, "format version")
+		assert.Equal(t, extra, s.Meta.Extra, "extra fields in order")
+		assert.Equal(t, "kubectl rollout restart deployment/web\n", s.Data, "body")
+
+		for _, format := range FrontMatters {
+			buf, err := s.marshalAs(format)
+			assert.Nil(t, err, "should render "+format)
+			assert.Equal(t, format, sniffFrontMatter(buf), "written as "+format)
+
+			var back Snippet
+			assert.Nil(t, back.Unmarshal(buf), "should parse "+format)
+			assert.True(t, created.Equal(*back.Meta.Created), "created survives "+format)
+			back.Meta.Created, back.Meta.raw = s.Meta.Created, s.Meta.raw
+			assert.Equal(t, s, back, "round trips "+format)
+		}
+	}
+
+	assert.NotNil(t, SetFrontMatter("xml"), "unknown format")
+	assert.Nil(t, SetFrontMatter("toml"), "toml is fine")
+	defer SetFrontMatter("yaml")
+	buf, _ := (&Snippet{Meta: metadata{Title: "x"}}).Marshal()
+	assert.True(t, strings.HasPrefix(string(buf), "+++\nuid = \"\"\ntitle = \"x\"\n"), "Marshal uses the default")
+}
+
+func TestDataStoreFrontMatter(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "creating temp dir failed")
+	defer os.RemoveAll(tmpdir)
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	fn := filepath.Join(tmpdir, "hugo.txt")
+	assert.Nil(t, ioutil.WriteFile(fn, []byte(tomlSnippet), 0644))
+
+	s, err := ds.Read("hugo.txt")
+	assert.Nil(t, err, "toml snippet is read")
+	s.Data = "kubectl get pods\n"
+	assert.Nil(t, ds.Write(s), "should write")
+
+	buf, _ := ioutil.ReadFile(fn)
+	assert.True(t, strings.HasPrefix(string(buf), "+++\n"), "toml is kept")
+	assert.Contains(t, string(buf), "[source]", "tables are kept")
+
+	uid, err := ds.New("fresh")
+	assert.Nil(t, err, "new snippet")
+	buf, _ = ioutil.ReadFile(filepath.Join(tmpdir, uid))
+	assert.True(t, strings.HasPrefix(string(buf), "---\n"), "new snippets use the default")
+}
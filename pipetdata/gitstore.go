@@ -0,0 +1,350 @@
+package pipetdata
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// History is implemented by stores that keep earlier versions of snippets.
+type History interface {
+	// Log lists the revisions of a snippet, newest first.
+	Log(id string) ([]Revision, error)
+	// Diff shows changes to a snippet since rev.
+	Diff(id, rev string) (string, error)
+	// Revert restores the snippet as it was at rev.
+	Revert(id, rev string) error
+}
+
+// Revision is a single recorded change to a snippet
+type Revision struct {
+	Hash    string
+	Date    string
+	Message string
+}
+
+// GitStore is a DataStore whose document directory is a git repository, every
+// change to a snippet is recorded as a commit.
+type GitStore struct {
+	*DataStore
+	batch bool // changes are committed at the end of a Batch
+}
+
+func init() {
+	Register("git", func(path string) (Store, error) {
+		ds, err := NewDataStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewGitStore(ds)
+	})
+}
+
+// NewGitStore turns the directory store into a git backed one, initializing
+// a repository with the existing snippets if there isn't one.
+func NewGitStore(d *DataStore) (*GitStore, error) {
+	g := &GitStore{DataStore: d}
+	if _, err := os.Stat(filepath.Join(d.documentDir, ".git")); err != nil {
+		if _, err := g.git("init", "-q"); err != nil {
+			return nil, errors.Wrap(err, "initializing repository failed")
+		}
+		err := d.locked(func() error {
+			if err := g.ignore(ignored...); err != nil {
+				return err
+			}
+			return g.commit("import existing snippets")
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing repository failed")
+		}
+	}
+	return g, g.ignore(ignored...)
+}
+
+// ignored lists what pipet keeps in the document directory that isn't part of
+// the snippets: the index, files doctor quarantined and half written files.
+var ignored = []string{"/" + indexFileName, "/" + quarantineDir + "/", tmpPrefix + "*"}
+
+// ignore keeps files out of history without committing a .gitignore
+func (g *GitStore) ignore(patterns ...string) error {
+	exclude := filepath.Join(g.documentDir, ".git", "info", "exclude")
+
+	buf, err := ioutil.ReadFile(exclude)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	have := map[string]bool{}
+	for _, l := range strings.Split(string(buf), "\n") {
+		have[l] = true
+	}
+
+	missing := []string{}
+	for _, p := range patterns {
+		if !have[p] {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(exclude), 0755); err != nil {
+		return err
+	}
+	if len(buf) > 0 && !bytes.HasSuffix(buf, []byte("\n")) {
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, strings.Join(missing, "\n")+"\n"...)
+	return ioutil.WriteFile(exclude, buf, 0644)
+}
+
+// git runs a git command inside the document directory and returns stdout.
+func (g *GitStore) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.documentDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// commit records every pending change in the document directory.
+func (g *GitStore) commit(msg string) error {
+	if g.batch {
+		return nil
+	}
+	if _, err := g.git("add", "-A", "."); err != nil {
+		return err
+	}
+
+	status, err := g.git("status", "--porcelain")
+	if err != nil || status == "" {
+		return err
+	}
+
+	args := []string{"commit", "-q", "-m", msg}
+	// don't refuse to work on machines without a configured identity
+	if _, err := g.git("config", "user.email"); err != nil {
+		args = append([]string{"-c", "user.name=pipet", "-c", "user.email=pipet@localhost"}, args...)
+	}
+	_, err = g.git(args...)
+	return err
+}
+
+func (g *GitStore) describe(id string) string {
+	if s, err := g.DataStore.Read(id); err == nil && s.Meta.Title != "" {
+		return fmt.Sprintf("%q (%s)", s.Meta.Title, id)
+	}
+	return id
+}
+
+// New creates the snippet and commits it
+func (g *GitStore) New(title string, tags ...string) (uid string, err error) {
+	err = g.locked(func() error {
+		uid, err = g.create(title, tags...)
+		if err != nil {
+			return err
+		}
+		return g.commit("new " + g.describe(uid))
+	})
+	return
+}
+
+// Write saves the snippet and commits the change
+func (g *GitStore) Write(s *Snippet) error {
+	return g.locked(func() error {
+		if err := g.write(s); err != nil {
+			return err
+		}
+		return g.commit("edit " + g.describe(s.Meta.UID))
+	})
+}
+
+// Delete removes the snippet and commits the removal, it can still be
+// recovered with Revert.
+func (g *GitStore) Delete(id string) error {
+	return g.locked(func() error {
+		desc := g.describe(id)
+		if err := g.remove(id); err != nil {
+			return err
+		}
+		return g.commit("delete " + desc)
+	})
+}
+
+// Archive moves the snippet to trash and commits the move
+func (g *GitStore) Archive(id string) error {
+	return g.locked(func() error {
+		if err := g.archive(id); err != nil {
+			return err
+		}
+		return g.commit("archive " + g.describe(id))
+	})
+}
+
+// Restore brings the snippet back from trash and commits the move
+func (g *GitStore) Restore(id string) error {
+	return g.locked(func() error {
+		if err := g.restore(id); err != nil {
+			return err
+		}
+		return g.commit("restore " + g.describe(id))
+	})
+}
+
+// Attach adds the file to the snippet and commits it
+func (g *GitStore) Attach(id string, a *Attachment) error {
+	return g.locked(func() error {
+		if err := g.attach(id, a); err != nil {
+			return err
+		}
+		return g.commit(fmt.Sprintf("attach %s to %s", a.Name, g.describe(id)))
+	})
+}
+
+// Detach removes the file from the snippet and commits the removal
+func (g *GitStore) Detach(id, name string) error {
+	return g.locked(func() error {
+		if err := g.detach(id, name); err != nil {
+			return err
+		}
+		return g.commit(fmt.Sprintf("detach %s from %s", name, g.describe(id)))
+	})
+}
+
+// Batch runs fn and commits everything it changed at once. What was done
+// before an error is committed too.
+func (g *GitStore) Batch(msg string, fn func() error) error {
+	if g.batch {
+		// already inside a batch, which will commit
+		return fn()
+	}
+	g.batch = true
+	err := fn()
+	g.batch = false
+
+	if e := g.locked(func() error { return g.commit(msg) }); err == nil {
+		err = e
+	}
+	return err
+}
+
+// paths where a snippet may have lived, live or in trash
+func snippetPaths(id string) []string {
+	return []string{id, filepath.Join(trashDir, id)}
+}
+
+// Log lists commits touching the snippet, newest first
+func (g *GitStore) Log(id string) ([]Revision, error) {
+	if !validID(id) {
+		return nil, errors.New("no such document")
+	}
+
+	args := []string{"log", "--format=%h%x1f%ad%x1f%s", "--date=short", "--"}
+	out, err := g.git(append(args, snippetPaths(id)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	revs := []Revision{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.SplitN(line, "\x1f", 3)
+		if len(f) == 3 {
+			revs = append(revs, Revision{Hash: f[0], Date: f[1], Message: f[2]})
+		}
+	}
+
+	if len(revs) == 0 {
+		return nil, errors.New("no history for snippet")
+	}
+	return revs, nil
+}
+
+// revision resolves rev to a commit hash. Anything git could take for an
+// option is refused, rev comes from the command line.
+func (g *GitStore) revision(rev string) (string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("bad revision %q", rev)
+	}
+	out, err := g.git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Diff shows the changes made to the snippet since rev
+func (g *GitStore) Diff(id, rev string) (string, error) {
+	if !validID(id) {
+		return "", errors.New("no such document")
+	}
+
+	hash, err := g.revision(rev)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"diff", hash, "--"}
+	return g.git(append(args, snippetPaths(id)...)...)
+}
+
+// Revert writes the snippet back as it was at rev and commits it. Only the
+// text and metadata of directory snippets are reverted, not their files.
+func (g *GitStore) Revert(id, rev string) error {
+	if !validID(id) {
+		return errors.New("no such document")
+	}
+
+	hash, err := g.revision(rev)
+	if err != nil {
+		return err
+	}
+
+	var buf string
+	for _, p := range snippetPaths(id) {
+		// a directory snippet keeps its text in the manifest
+		for _, fn := range []string{filepath.Join(p, manifestName), p} {
+			buf, err = g.git("show", fmt.Sprintf("%s:%s", hash, filepath.ToSlash(fn)))
+			if err == nil {
+				break
+			}
+		}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "snippet not found at %s", rev)
+	}
+
+	s := &Snippet{}
+	if err := s.Unmarshal([]byte(buf)); err != nil {
+		return errors.Wrap(err, "old revision is invalid")
+	}
+	s.Meta.UID = id
+	s.Touch()
+
+	return g.locked(func() error {
+		// drop whichever copy is there now, so the snippet ends up in one
+		// place; directory snippets keep their files
+		for _, p := range snippetPaths(id) {
+			os.Remove(filepath.Join(g.documentDir, p))
+			os.Remove(filepath.Join(g.documentDir, p, manifestName))
+		}
+
+		if err := g.write(s); err != nil {
+			return err
+		}
+		return g.commit(fmt.Sprintf("revert %s to %s", g.describe(id), rev))
+	})
+}
@@ -0,0 +1,149 @@
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitStore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	s, err := Open("git://" + tmpdir)
+	assert.Nil(t, err, "opening git store")
+	testStore(t, s)
+
+	g := s.(*GitStore)
+	uid, err := g.New("Kernel version", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+
+	sn, _ := g.Read(uid)
+	sn.Data = "uname -a\n"
+	assert.Nil(t, g.Write(sn), "first edit")
+	sn.Data = "uname -r\n"
+	assert.Nil(t, g.Write(sn), "second edit")
+
+	revs, err := g.Log(uid)
+	assert.Nil(t, err, "log should work")
+	assert.Len(t, revs, 3, "one commit for new and one per edit")
+	assert.Contains(t, revs[2].Message, "new", "oldest commit creates the snippet")
+
+	diff, err := g.Diff(uid, revs[1].Hash)
+	assert.Nil(t, err, "diff should work")
+	assert.Contains(t, diff, "-uname -a", "diff shows removed line")
+	assert.Contains(t, diff, "+uname -r", "diff shows added line")
+
+	outFile := filepath.Join(tmpdir, "out")
+	for _, rev := range []string{"--output=" + outFile, "-p", "nosuchrev", ""} {
+		_, err = g.Diff(uid, rev)
+		assert.NotNil(t, err, "diff should refuse revision %q", rev)
+		assert.NotNil(t, g.Revert(uid, rev), "revert should refuse revision %q", rev)
+	}
+	_, err = os.Stat(outFile)
+	assert.True(t, os.IsNotExist(err), "revision is not taken as an option")
+
+	assert.Nil(t, g.Revert(uid, revs[1].Hash), "revert should work")
+	sn, _ = g.Read(uid)
+	assert.Equal(t, "uname -a\n", sn.Data, "data should be back")
+
+	revs, _ = g.Log(uid)
+	assert.Len(t, revs, 4, "revert is recorded")
+
+	assert.Nil(t, g.Archive(uid), "archive should work")
+	assert.Nil(t, g.Revert(uid, revs[0].Hash), "revert brings back archived snippets")
+	assert.True(t, g.Exist(uid), "snippet should be live again")
+
+	assert.Nil(t, g.Attach(uid, &Attachment{Name: "run.sh", Data: []byte("uname -a\n")}), "attach should work")
+	revs, _ = g.Log(uid)
+	assert.Contains(t, revs[0].Message, "attach run.sh", "attaching is recorded")
+
+	sn.Data = "uname -m\n"
+	assert.Nil(t, g.Write(sn), "edit of a directory snippet")
+	assert.Nil(t, g.Revert(uid, revs[0].Hash), "revert of a directory snippet")
+	sn, _ = g.Read(uid)
+	assert.Equal(t, "uname -a\n", sn.Data, "data should be back")
+	assert.Equal(t, []string{"run.sh"}, sn.Meta.Files, "files are kept")
+
+	assert.Nil(t, g.Detach(uid, "run.sh"), "detach should work")
+	out, err := g.git("status", "--porcelain")
+	assert.Nil(t, err, "git status")
+	assert.Empty(t, out, "every change is committed")
+}
+
+func TestGitStoreBatch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	s, err := Open("git://" + tmpdir)
+	assert.Nil(t, err, "opening git store")
+	g := s.(*GitStore)
+
+	uids := []string{}
+	err = Batch(g, "add two snippets", func() error {
+		for _, title := range []string{"Kernel version", "Uptime"} {
+			uid, err := g.New(title, "linux")
+			if err != nil {
+				return err
+			}
+			uids = append(uids, uid)
+		}
+		return Batch(g, "nested", func() error { return g.Archive(uids[1]) })
+	})
+	assert.Nil(t, err, "batch should work")
+
+	for _, uid := range uids {
+		revs, err := g.Log(uid)
+		assert.Nil(t, err, "log should work")
+		assert.Len(t, revs, 1, "one commit for the whole batch")
+		assert.Equal(t, "add two snippets", revs[0].Message, "batch message is used")
+	}
+
+	assert.Nil(t, Batch(NewMemStore(), "no batching", func() error { return nil }), "other stores just run fn")
+}
+
+func TestGitStoreIgnore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+	s, err := Open("git://" + tmpdir)
+	assert.Nil(t, err, "opening git store")
+	g := s.(*GitStore)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(tmpdir, quarantineDir), 0755), "quarantine directory")
+	assert.Nil(t, os.MkdirAll(filepath.Join(tmpdir, trashDir), 0755), "trash directory")
+	for _, name := range []string{
+		filepath.Join(quarantineDir, "broken.txt"),
+		tmpPrefix + "1234",
+		filepath.Join(trashDir, tmpPrefix+"5678"),
+	} {
+		err := ioutil.WriteFile(filepath.Join(tmpdir, name), []byte("junk"), 0644)
+		assert.Nil(t, err, "writing test file")
+	}
+
+	out, err := g.git("status", "--porcelain")
+	assert.Nil(t, err, "git status")
+	assert.Empty(t, out, "quarantined and temporary files are not tracked")
+
+	_, err = NewGitStore(g.DataStore)
+	assert.Nil(t, err, "reopening git store")
+	buf, err := ioutil.ReadFile(filepath.Join(tmpdir, ".git", "info", "exclude"))
+	assert.Nil(t, err, "reading exclude file")
+	assert.Equal(t, 1, strings.Count(string(buf), "/"+quarantineDir+"/"), "patterns are added once")
+}
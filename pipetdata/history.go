@@ -0,0 +1,204 @@
+package pipetdata
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryShells are the shells ReadHistory understands
+var HistoryShells = []string{"bash", "zsh", "fish"}
+
+// HistoryEntry is a command from a shell history file, Count times run and
+// last at Last (zero if the history has no timestamps).
+type HistoryEntry struct {
+	Command string
+	Count   int
+	Last    time.Time
+}
+
+// ReadHistory parses a shell history file, one entry per command run.
+//
+// bash history is a command per line, or with HISTTIMEFORMAT set every command
+// (possibly spanning lines) follows a #<unix time> line. zsh history is either
+// plain or in the extended `: <unix time>:<duration>;command` format, lines
+// ending in a backslash continue the command. fish keeps a yaml like list of
+// `- cmd: command` items with `when: <unix time>`.
+func ReadHistory(r io.Reader, shell string) ([]HistoryEntry, error) {
+	switch shell {
+	case "bash":
+		return readBashHistory(r)
+	case "zsh":
+		return readZshHistory(r)
+	case "fish":
+		return readFishHistory(r)
+	}
+	return nil, fmt.Errorf("unknown shell %q, valid shells are %s", shell, strings.Join(HistoryShells, ", "))
+}
+
+func historyLines(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	return sc
+}
+
+func unixTime(s string) (time.Time, bool) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(n, 0), true
+}
+
+func readBashHistory(r io.Reader) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	stamped := false
+
+	sc := historyLines(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			if t, ok := unixTime(line[1:]); ok {
+				stamped = true
+				entries = append(entries, HistoryEntry{Count: 1, Last: t})
+				continue
+			}
+		}
+
+		// with timestamps, lines up to the next one belong to the same command
+		if stamped && len(entries) > 0 {
+			e := &entries[len(entries)-1]
+			if e.Command != "" {
+				e.Command += "\n"
+			}
+			e.Command += line
+			continue
+		}
+		entries = append(entries, HistoryEntry{Command: line, Count: 1})
+	}
+	return entries, sc.Err()
+}
+
+// unmetafy undoes zsh's escaping of non ascii bytes in history files, a 0x83
+// byte marks the next one as xor-ed with 32.
+func unmetafy(buf []byte) []byte {
+	out := buf[:0]
+	for i := 0; i < len(buf); i++ {
+		if buf[i] == 0x83 && i+1 < len(buf) {
+			i++
+			out = append(out, buf[i]^32)
+			continue
+		}
+		out = append(out, buf[i])
+	}
+	return out
+}
+
+func readZshHistory(r io.Reader) ([]HistoryEntry, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []HistoryEntry{}
+	var cur *HistoryEntry
+
+	sc := historyLines(strings.NewReader(string(unmetafy(buf))))
+	for sc.Scan() {
+		line := sc.Text()
+
+		if cur == nil {
+			e := HistoryEntry{Count: 1}
+			if strings.HasPrefix(line, ": ") {
+				if i := strings.Index(line, ";"); i != -1 {
+					stamp := strings.SplitN(line[2:i], ":", 2)[0]
+					if t, ok := unixTime(stamp); ok {
+						e.Last = t
+						line = line[i+1:]
+					}
+				}
+			}
+			entries = append(entries, e)
+			cur = &entries[len(entries)-1]
+		} else {
+			cur.Command += "\n"
+		}
+
+		if strings.HasSuffix(line, `\`) {
+			cur.Command += strings.TrimSuffix(line, `\`)
+			continue
+		}
+		cur.Command += line
+		cur = nil
+	}
+	return entries, sc.Err()
+}
+
+func readFishHistory(r io.Reader) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	unescape := strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+
+	sc := historyLines(r)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "- cmd: "):
+			cmd := unescape.Replace(strings.TrimPrefix(line, "- cmd: "))
+			entries = append(entries, HistoryEntry{Command: cmd, Count: 1})
+		case strings.HasPrefix(line, "  when: ") && len(entries) > 0:
+			if t, ok := unixTime(strings.TrimPrefix(line, "  when: ")); ok {
+				entries[len(entries)-1].Last = t
+			}
+		}
+	}
+	return entries, sc.Err()
+}
+
+// RankHistory merges repeated commands and orders them by how useful they
+// look as snippets: commands run often rank high, but so do long ones, which
+// are the ones worth keeping. The score is Count * log2(1 + length), ties go
+// to the most recently run.
+func RankHistory(entries []HistoryEntry) []HistoryEntry {
+	merged := map[string]*HistoryEntry{}
+	ranked := []*HistoryEntry{}
+
+	for _, e := range entries {
+		cmd := strings.TrimSpace(e.Command)
+		if cmd == "" {
+			continue
+		}
+		m, ok := merged[cmd]
+		if !ok {
+			m = &HistoryEntry{Command: cmd}
+			merged[cmd] = m
+			ranked = append(ranked, m)
+		}
+		m.Count += e.Count
+		if e.Last.After(m.Last) {
+			m.Last = e.Last
+		}
+	}
+
+	score := func(e *HistoryEntry) float64 {
+		return float64(e.Count) * math.Log2(1+float64(len(e.Command)))
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		si, sj := score(ranked[i]), score(ranked[j])
+		if si != sj {
+			return si > sj
+		}
+		return ranked[i].Last.After(ranked[j].Last)
+	})
+
+	out := make([]HistoryEntry, len(ranked))
+	for i, e := range ranked {
+		out[i] = *e
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package pipetdata
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commands(entries []HistoryEntry) []string {
+	cmds := []string{}
+	for _, e := range entries {
+		cmds = append(cmds, e.Command)
+	}
+	return cmds
+}
+
+func TestReadHistory(t *testing.T) {
+	bash, err := ReadHistory(strings.NewReader("ls\ngit status\n"), "bash")
+	assert.Nil(t, err, "should parse")
+	assert.Equal(t, []string{"ls", "git status"}, commands(bash), "a command per line")
+
+	bash, err = ReadHistory(strings.NewReader("#1500000000\nfor f in *; do\n  echo $f\ndone\n#1500000100\nls\n"), "bash")
+	assert.Nil(t, err, "should parse")
+	assert.Equal(t, []string{"for f in *; do\n  echo $f\ndone", "ls"}, commands(bash), "timestamps delimit commands")
+	assert.Equal(t, time.Unix(1500000100, 0), bash[1].Last, "timestamp")
+
+	zsh, err := ReadHistory(strings.NewReader(": 1500000000:0;ls\n: 1500000005:3;docker run \\\n  --rm alpine\nplain\ncaf\x83\xa3\n"), "zsh")
+	assert.Nil(t, err, "should parse")
+	assert.Equal(t, []string{"ls", "docker run \n  --rm alpine", "plain", "caf\x83"}, commands(zsh), "extended format")
+	assert.Equal(t, time.Unix(1500000005, 0), zsh[1].Last, "timestamp")
+
+	fish, err := ReadHistory(strings.NewReader("- cmd: ls\n  when: 1500000000\n- cmd: echo a\\nb \\\\\n  when: 1500000001\n  paths:\n    - b\n"), "fish")
+	assert.Nil(t, err, "should parse")
+	assert.Equal(t, []string{"ls", "echo a\nb \\"}, commands(fish), "escapes")
+	assert.Equal(t, time.Unix(1500000001, 0), fish[1].Last, "timestamp")
+
+	_, err = ReadHistory(strings.NewReader(""), "csh")
+	assert.NotNil(t, err, "unknown shell")
+}
+
+func TestRankHistory(t *testing.T) {
+	entries := []HistoryEntry{
+		{Command: "ls", Count: 1},
+		{Command: "kubectl get pods -n kube-system", Count: 1, Last: time.Unix(10, 0)},
+		{Command: "ls ", Count: 1},
+		{Command: "ls", Count: 1, Last: time.Unix(20, 0)},
+		{Command: "  ", Count: 1},
+		{Command: "kubectl get pods -n kube-public", Count: 1, Last: time.Unix(30, 0)},
+	}
+	ranked := RankHistory(entries)
+	assert.Equal(t, []string{"kubectl get pods -n kube-public", "kubectl get pods -n kube-system", "ls"},
+		commands(ranked), "long commands beat short ones, ties by recency")
+	assert.Equal(t, 3, ranked[2].Count, "repeats are merged")
+	assert.Equal(t, time.Unix(20, 0), ranked[2].Last, "last run is kept")
+}
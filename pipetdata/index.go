@@ -0,0 +1,241 @@
+package pipetdata
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// indexFileName caches snippet metadata, relative to documentDir. It is gob
+// encoded, decoding has to be fast for large stores.
+const indexFileName = ".index"
+
+// bump when indexEntry changes, older indexes are rebuilt from scratch
+const indexVersion = 4
+
+// indexEntry is the cached metadata of one snippet file, it is trusted as long
+// as the file's mtime and size are unchanged.
+type indexEntry struct {
+	Meta    metadata // without Extra, gob can't encode arbitrary yaml values
+	Extra   []byte   // Meta.Extra as yaml
+	ModTime int64    // unix nanoseconds
+	Size    int64
+	Hash    string
+}
+
+type metaIndex struct {
+	Version int
+	Entries map[string]*indexEntry
+}
+
+func (d *DataStore) indexPath() string {
+	return filepath.Join(d.documentDir, indexFileName)
+}
+
+// loadIndex reads the index, a missing or unreadable index is empty.
+func (d *DataStore) loadIndex() *metaIndex {
+	idx := &metaIndex{}
+	buf, err := ioutil.ReadFile(d.indexPath())
+	if err != nil || gob.NewDecoder(bytes.NewReader(buf)).Decode(idx) != nil || idx.Version != indexVersion {
+		idx = &metaIndex{}
+	}
+
+	if idx.Entries == nil {
+		idx.Version = indexVersion
+		idx.Entries = map[string]*indexEntry{}
+	}
+	return idx
+}
+
+// saveIndex replaces the index on disk. Callers hold the store lock so they
+// don't overwrite each other's entries.
+func (d *DataStore) saveIndex(idx *metaIndex) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(idx); err != nil {
+		return err
+	}
+	return writeFileAtomic(d.indexPath(), buf.Bytes(), 0644)
+}
+
+func newIndexEntry(s *Snippet, buf []byte, fi os.FileInfo) *indexEntry {
+	sum := sha1.Sum(buf)
+	e := &indexEntry{
+		Meta:    s.Meta,
+		ModTime: fi.ModTime().UnixNano(),
+		Size:    fi.Size(),
+		Hash:    hex.EncodeToString(sum[:]),
+	}
+
+	if len(s.Meta.Extra) > 0 {
+		// it came out of yaml, it goes back in
+		e.Extra, _ = s.Meta.marshalExtra()
+		e.Meta.Extra = nil
+	}
+	return e
+}
+
+// current reports whether e still describes the snippet file of id
+func (d *DataStore) current(id string, e *indexEntry) bool {
+	fi, err := d.snippetInfo(id)
+	return err == nil && e.ModTime == fi.ModTime().UnixNano() && e.Size == fi.Size()
+}
+
+// snippetInfo stats the file holding the snippet text
+func (d *DataStore) snippetInfo(id string) (os.FileInfo, error) {
+	fi, err := os.Stat(d.path(id))
+	if err != nil {
+		return nil, err
+	}
+	return statSnippet(d.path(id), fi)
+}
+
+// meta returns the cached metadata with its extra fields
+func (e *indexEntry) meta() (meta metadata, err error) {
+	meta = e.Meta
+	if len(e.Extra) > 0 {
+		meta.Extra, meta.raw, err = extraFields(e.Extra)
+	}
+	return
+}
+
+// indexFile records a freshly written snippet file in the index.
+func (d *DataStore) indexFile(s *Snippet, buf []byte) error {
+	id := s.Meta.UID
+	fi, err := d.snippetInfo(id)
+	if err != nil {
+		return err
+	}
+
+	c := *s
+	c.Meta.Files = nil
+	if isDirSnippet(d.path(id)) {
+		if c.Meta.Files, err = dirFiles(d.path(id)); err != nil {
+			return err
+		}
+	}
+
+	idx := d.loadIndex()
+	idx.Entries[id] = newIndexEntry(&c, buf, fi)
+	return d.saveIndex(idx)
+}
+
+// unindexFile drops a snippet from the index.
+func (d *DataStore) unindexFile(id string) error {
+	idx := d.loadIndex()
+	if _, ok := idx.Entries[id]; !ok {
+		return nil
+	}
+	delete(idx.Entries, id)
+	return d.saveIndex(idx)
+}
+
+// listIndexed returns the metadata of every snippet in documentDir, only files
+// that changed since they were last indexed are read.
+func (d *DataStore) listIndexed() (sns []*Snippet, err error) {
+	sns = []*Snippet{}
+
+	fli, err := ioutil.ReadDir(d.documentDir)
+	if err != nil {
+		return
+	}
+
+	idx := d.loadIndex()
+	seen := map[string]bool{}
+	updated := map[string]*indexEntry{}
+	removed := map[string]string{} // uid to the hash of the dropped entry
+	lerr := &ListError{Errs: map[string]error{}}
+
+	for _, f := range fli {
+		id := f.Name()
+		if !validID(id) {
+			continue
+		}
+		// a directory without a manifest is not a snippet
+		if f, err = statSnippet(d.path(id), f); err != nil {
+			continue
+		}
+		seen[id] = true
+
+		e, ok := idx.Entries[id]
+		if !ok || e.ModTime != f.ModTime().UnixNano() || e.Size != f.Size() {
+			e, err = d.readIndexEntry(id, f)
+			if err != nil {
+				lerr.Errs[id] = err
+				delete(idx.Entries, id)
+				removed[id] = ""
+				continue
+			}
+			idx.Entries[id] = e
+			updated[id] = e
+		}
+
+		meta, err := e.meta()
+		if err != nil {
+			lerr.Errs[id] = err
+			delete(idx.Entries, id)
+			removed[id] = e.Hash
+			continue
+		}
+		sns = append(sns, &Snippet{Meta: meta})
+	}
+
+	for id := range idx.Entries {
+		if !seen[id] {
+			removed[id] = ""
+		}
+	}
+
+	if len(updated) > 0 || len(removed) > 0 {
+		// a failure here only costs speed on the next List
+		d.updateIndex(updated, removed)
+	}
+
+	err = nil
+	if len(lerr.Errs) > 0 {
+		err = lerr
+	}
+	return
+}
+
+// updateIndex saves what List refreshed. Snippets may have been written since
+// they were read, so the index is reloaded under the store lock and entries
+// written in the meantime are kept. Removed entries are dropped if they are
+// stale or the very entry List couldn't use.
+func (d *DataStore) updateIndex(updated map[string]*indexEntry, removed map[string]string) error {
+	return d.locked(func() error {
+		idx := d.loadIndex()
+		for id, e := range updated {
+			if d.current(id, e) {
+				idx.Entries[id] = e
+			}
+		}
+		for id, hash := range removed {
+			if e, ok := idx.Entries[id]; ok && (e.Hash == hash || !d.current(id, e)) {
+				delete(idx.Entries, id)
+			}
+		}
+		return d.saveIndex(idx)
+	})
+}
+
+func (d *DataStore) readIndexEntry(id string, fi os.FileInfo) (*indexEntry, error) {
+	buf, err := ioutil.ReadFile(d.Fullpath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snippet{}
+	if err := s.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	if isDirSnippet(d.path(id)) {
+		if s.Meta.Files, err = dirFiles(d.path(id)); err != nil {
+			return nil, err
+		}
+	}
+	return newIndexEntry(s, buf, fi), nil
+}
@@ -0,0 +1,7 @@
+package pipetdata
+
+// lockFile is a no-op on windows, writes are still atomic but concurrent pipet
+// processes are not kept apart.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,275 @@
+package pipetdata
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/satori/go.uuid"
+	"gopkg.in/yaml.v2"
+)
+
+// A markdown notebook has a section per snippet:
+//
+//	## Restart web
+//
+//	Tags: `k8s`, `ops`
+//
+//	Rolls the web deployment, wait for it with rollout status.
+//
+//	```bash
+//	kubectl rollout restart deployment/web
+//	```
+//
+//	<!-- pipet
+//	uid: 0c2a2d5e-....txt
+//	owner: ops
+//	-->
+//
+// Any heading with fenced code blocks under it (before the next heading)
+// is a snippet, the code blocks make up the body and other text the
+// description. The tag line and the pipet comment are optional, without a
+// uid the snippet gets one derived from its title so importing the same
+// document again updates it. Sections sharing a title get theirs from the
+// headings above them, or their position if those are the same too. A fence
+// info string that isn't a tag is kept as the language.
+var markdownNamespace = uuid.NewV5(uuid.NamespaceURL, "https://github.com/dbalan/pipet/markdown")
+
+var (
+	headingRe = regexp.MustCompile("^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$")
+	fenceRe   = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^ \t`]*)")
+	tagLineRe = regexp.MustCompile(`(?i)^tags:\s*(.*)$`)
+	blanksRe  = regexp.MustCompile(`\n\s*\n(\s*\n)+`)
+)
+
+// markdownSection is a heading and what follows it
+type markdownSection struct {
+	title   string
+	path    []string // enclosing headings and title
+	tags    []string
+	lang    string
+	text    []string
+	code    []string
+	comment []string
+}
+
+// ReadMarkdown reads snippets from a markdown document
+func ReadMarkdown(r io.Reader) ([]*Snippet, error) {
+	sections := []*markdownSection{}
+	var cur *markdownSection
+	headings := []string{}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence := m[1]
+			block := []string{}
+			for sc.Scan() {
+				l := sc.Text()
+				if t := strings.TrimSpace(l); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+					break
+				}
+				block = append(block, l)
+			}
+			if cur != nil {
+				if len(cur.code) == 0 {
+					cur.lang = m[2]
+				}
+				cur.code = append(cur.code, strings.Join(block, "\n"))
+			}
+			continue
+		}
+
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			if level := len(m[1]); level <= len(headings) {
+				headings = headings[:level-1]
+			}
+			headings = append(headings, m[2])
+			cur = &markdownSection{title: m[2], path: append([]string{}, headings...)}
+			sections = append(sections, cur)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		if m := tagLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil && cur.tags == nil {
+			cur.tags = []string{}
+			for _, t := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' }) {
+				if t = strings.Trim(t, "`#"); t != "" {
+					cur.tags = append(cur.tags, t)
+				}
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "<!-- pipet" {
+			for sc.Scan() && strings.TrimSpace(sc.Text()) != "-->" {
+				cur.comment = append(cur.comment, sc.Text())
+			}
+			continue
+		}
+		cur.text = append(cur.text, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	snippets := []*markdownSection{}
+	for _, sec := range sections {
+		if len(sec.code) > 0 {
+			snippets = append(snippets, sec)
+		}
+	}
+
+	sns := []*Snippet{}
+	titles := map[string]string{}
+	for i, key := range sectionKeys(snippets) {
+		s, err := snippets[i].snippet(key)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := titles[s.Meta.UID]; ok {
+			return nil, fmt.Errorf("%q and %q have the same uid %s", t, s.Meta.Title, s.Meta.UID)
+		}
+		titles[s.Meta.UID] = s.Meta.Title
+		sns = append(sns, s)
+	}
+	return sns, nil
+}
+
+// sectionKeys names each section uniquely to derive its uid from: its title,
+// the path of headings down to it if the title is taken, and its position
+// among sections with the same path if that is too.
+func sectionKeys(sections []*markdownSection) []string {
+	keys := make([]string, len(sections))
+	count := map[string]int{}
+	for i, sec := range sections {
+		keys[i] = sec.title
+		count[keys[i]]++
+	}
+
+	paths := map[string]int{}
+	for i, sec := range sections {
+		if count[sec.title] > 1 {
+			keys[i] = strings.Join(sec.path, "\x00")
+			paths[keys[i]]++
+		}
+	}
+
+	seen := map[string]int{}
+	for i, key := range keys {
+		if paths[key] > 1 {
+			seen[key]++
+			keys[i] = fmt.Sprintf("%s\x00%d", key, seen[key])
+		}
+	}
+	return keys
+}
+
+func (sec *markdownSection) snippet(key string) (*Snippet, error) {
+	s := &Snippet{
+		Meta: metadata{Title: sec.title, Tags: sec.tags},
+		Data: strings.Join(sec.code, "\n") + "\n",
+	}
+
+	if len(sec.comment) > 0 {
+		fields := yaml.MapSlice{}
+		if err := yaml.Unmarshal([]byte(strings.Join(sec.comment, "\n")), &fields); err != nil {
+			return nil, fmt.Errorf("bad pipet comment under %q: %v", sec.title, err)
+		}
+		for _, f := range fields {
+			if f.Key == "uid" {
+				s.Meta.UID = fmt.Sprint(f.Value)
+				continue
+			}
+			s.Meta.Extra = append(s.Meta.Extra, f)
+		}
+	}
+	if s.Meta.UID == "" {
+		s.Meta.UID = fmt.Sprintf("%s.txt", uuid.NewV5(markdownNamespace, key))
+	}
+
+	// paragraphs around code blocks leave runs of blank lines behind
+	text := blanksRe.ReplaceAllString(strings.Join(sec.text, "\n"), "\n\n")
+	if text = strings.TrimSpace(text); text != "" {
+		if err := s.SetField("description", text); err != nil {
+			return nil, err
+		}
+	}
+	if sec.lang != "" && !hasTag(s, sec.lang) {
+		if err := s.SetField("language", sec.lang); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func hasTag(s *Snippet, tag string) bool {
+	for _, t := range s.Meta.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteMarkdown writes snippets as a markdown notebook ReadMarkdown reads
+// back. The description and language extra fields are written as text and the
+// code block's language, other extra fields go into the pipet comment.
+func WriteMarkdown(w io.Writer, sns []*Snippet) error {
+	bw := bufio.NewWriter(w)
+	for i, s := range sns {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "## %s\n\n", s.Meta.Title)
+
+		if len(s.Meta.Tags) > 0 {
+			fmt.Fprintf(bw, "Tags: `%s`\n\n", strings.Join(s.Meta.Tags, "`, `"))
+		}
+
+		if d, ok := s.Field("description"); ok {
+			fmt.Fprintf(bw, "%s\n\n", strings.TrimSpace(fmt.Sprint(d)))
+		}
+
+		fence := "```"
+		for strings.Contains(s.Data, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(bw, "%s%s\n%s\n%s\n\n", fence, markdownLanguage(s),
+			strings.TrimSuffix(s.Data, "\n"), fence)
+
+		comment := yaml.MapSlice{{Key: "uid", Value: s.Meta.UID}}
+		for _, f := range s.Meta.Extra {
+			if f.Key != "description" && f.Key != "language" {
+				comment = append(comment, f)
+			}
+		}
+		c, err := yaml.Marshal(comment)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "<!-- pipet\n%s-->\n", c)
+	}
+	return bw.Flush()
+}
+
+// markdownLanguage is the info string for the body's code block
+func markdownLanguage(s *Snippet) string {
+	if lang, ok := s.Field("language"); ok {
+		return fmt.Sprint(lang)
+	}
+	for _, t := range s.Meta.Tags {
+		if lexers.Get(t) != nil {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package pipetdata
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+var runbook = "# Runbook\n\nSome intro.\n\n" +
+	"## Restart web ##\n\nTags: `k8s`, `ops`\n\nRolls the deployment.\n\n" +
+	"```bash\nkubectl rollout restart deployment/web\n```\n\nThen wait:\n\n" +
+	"~~~\nkubectl rollout status deployment/web\n~~~\n\n" +
+	"## Notes\n\nNo code here.\n\n" +
+	"### Show markdown\n\n````markdown\n```go\nfmt.Println()\n```\n````\n\n" +
+	"<!-- pipet\nuid: md.txt\nowner: ops\n-->\n"
+
+func TestReadMarkdown(t *testing.T) {
+	sns, err := ReadMarkdown(strings.NewReader(runbook))
+	assert.Nil(t, err, "should parse")
+	assert.Len(t, sns, 2, "headings without code are skipped")
+
+	web := sns[0]
+	assert.Equal(t, "Restart web", web.Meta.Title, "heading is the title")
+	assert.Equal(t, []string{"k8s", "ops"}, web.Meta.Tags, "tag line")
+	assert.Equal(t, "kubectl rollout restart deployment/web\nkubectl rollout status deployment/web\n",
+		web.Data, "code blocks are the body")
+	assert.Equal(t, yaml.MapSlice{
+		{Key: "description", Value: "Rolls the deployment.\n\nThen wait:"},
+		{Key: "language", Value: "bash"},
+	}, web.Meta.Extra, "text and fence language")
+
+	again, _ := ReadMarkdown(strings.NewReader(runbook))
+	assert.Equal(t, web.Meta.UID, again[0].Meta.UID, "uid from the title is stable")
+
+	md := sns[1]
+	assert.Equal(t, "md.txt", md.Meta.UID, "uid from the pipet comment")
+	assert.Equal(t, "```go\nfmt.Println()\n```\n", md.Data, "longer fences nest")
+	assert.Equal(t, yaml.MapSlice{
+		{Key: "owner", Value: "ops"},
+		{Key: "language", Value: "markdown"},
+	}, md.Meta.Extra, "comment fields are extra metadata")
+}
+
+func TestReadMarkdownSameTitle(t *testing.T) {
+	doc := "# Foo\n\n## Usage\n\n```\nfoo\n```\n\n# Bar\n\n## Usage\n\n```\nbar\n```\n\n" +
+		"## Usage\n\n```\nbar -v\n```\n\n## Build\n\n```\nmake\n```\n"
+	sns, err := ReadMarkdown(strings.NewReader(doc))
+	assert.Nil(t, err, "should parse")
+	assert.Len(t, sns, 4, "every section is a snippet")
+
+	uids := map[string]bool{}
+	for _, s := range sns {
+		uids[s.Meta.UID] = true
+	}
+	assert.Len(t, uids, 4, "sections with the same title get their own uid")
+
+	single, _ := ReadMarkdown(strings.NewReader("## Build\n\n```\nmake\n```\n"))
+	assert.Equal(t, single[0].Meta.UID, sns[3].Meta.UID, "unique titles still derive the uid")
+
+	dup := "## A\n\n```\na\n```\n\n<!-- pipet\nuid: x.txt\n-->\n\n## B\n\n```\nb\n```\n\n<!-- pipet\nuid: x.txt\n-->\n"
+	_, err = ReadMarkdown(strings.NewReader(dup))
+	assert.NotNil(t, err, "the same uid twice is refused")
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	sns, err := ReadMarkdown(strings.NewReader(runbook))
+	assert.Nil(t, err, "should parse")
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteMarkdown(&buf, sns), "should write")
+	assert.Contains(t, buf.String(), "## Restart web\n\nTags: `k8s`, `ops`\n\nRolls the deployment.\n\nThen wait:\n\n```bash\n")
+	assert.Contains(t, buf.String(), "````markdown\n```go\n", "fence longer than the body's")
+
+	back, err := ReadMarkdown(&buf)
+	assert.Nil(t, err, "should read its own output")
+	assert.Equal(t, sns, back, "round trips")
+
+	plain := &Snippet{Meta: metadata{UID: "p.txt", Title: "Plain", Tags: []string{"linux", "go"}}, Data: "go vet\n"}
+	buf.Reset()
+	assert.Nil(t, WriteMarkdown(&buf, []*Snippet{plain}), "should write")
+	assert.Equal(t, "## Plain\n\nTags: `linux`, `go`\n\n```go\ngo vet\n```\n\n<!-- pipet\nuid: p.txt\n-->\n",
+		buf.String(), "language from tags")
+}
@@ -0,0 +1,196 @@
+package pipetdata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// MemStore keeps snippets in memory, it is mostly useful for tests.
+type MemStore struct {
+	snippets map[string]*Snippet
+	files    map[string]map[string]*Attachment
+}
+
+// NewMemStore creates an empty in-memory store.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		snippets: map[string]*Snippet{},
+		files:    map[string]map[string]*Attachment{},
+	}
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copySnippet(s *Snippet) *Snippet {
+	c := *s
+	c.Meta.Tags = append([]string(nil), s.Meta.Tags...)
+	c.Meta.Created = copyTime(s.Meta.Created)
+	c.Meta.Updated = copyTime(s.Meta.Updated)
+	c.Meta.Archived = copyTime(s.Meta.Archived)
+	c.Meta.Extra = append(yaml.MapSlice(nil), s.Meta.Extra...)
+	c.Meta.Files = append([]string(nil), s.Meta.Files...)
+	return &c
+}
+
+func copyAttachment(a *Attachment) *Attachment {
+	c := *a
+	c.Data = append([]byte(nil), a.Data...)
+	return &c
+}
+
+// read returns a copy of the snippet with its attachments listed
+func (m *MemStore) read(s *Snippet) *Snippet {
+	c := copySnippet(s)
+	c.Meta.Files = nil
+	for name := range m.files[s.Meta.UID] {
+		c.Meta.Files = append(c.Meta.Files, name)
+	}
+	sort.Strings(c.Meta.Files)
+	return c
+}
+
+// Exist checks if a live snippet with the uid exists.
+func (m *MemStore) Exist(id string) bool {
+	s, ok := m.snippets[id]
+	return ok && s.Meta.Archived == nil
+}
+
+// New creates a new entry in snippets
+func (m *MemStore) New(title string, tags ...string) (string, error) {
+	uid := newID()
+	if m.Exist(uid) {
+		return "", errors.New("duplicate snippet")
+	}
+
+	m.snippets[uid] = &Snippet{Meta: newMetadata(uid, title, tags)}
+	return uid, nil
+}
+
+// Read returns a copy of the snippet
+func (m *MemStore) Read(id string) (*Snippet, error) {
+	s, ok := m.snippets[id]
+	if !ok {
+		return nil, errors.New("no such document")
+	}
+	return m.read(s), nil
+}
+
+// Write stores a copy of s
+func (m *MemStore) Write(s *Snippet) error {
+	if s.Meta.UID == "" {
+		return errors.New("snippet has no uid")
+	}
+	c := copySnippet(s)
+	c.Meta.upgrade(time.Now())
+	m.snippets[s.Meta.UID] = c
+	return nil
+}
+
+func (m *MemStore) filter(archived bool) []*Snippet {
+	sns := []*Snippet{}
+	for _, s := range m.snippets {
+		if (s.Meta.Archived != nil) == archived {
+			sns = append(sns, m.read(s))
+		}
+	}
+	sort.Slice(sns, func(i, j int) bool {
+		return sns[i].Meta.UID < sns[j].Meta.UID
+	})
+	return sns
+}
+
+// List returns all live snippets ordered by uid
+func (m *MemStore) List() (sns []*Snippet, err error) {
+	sns = m.filter(false)
+	if len(sns) == 0 {
+		err = ErrEmptyStore
+	}
+	return
+}
+
+// Archived returns all snippets in the trash ordered by uid
+func (m *MemStore) Archived() ([]*Snippet, error) {
+	return m.filter(true), nil
+}
+
+// Archive marks the snippet as deleted
+func (m *MemStore) Archive(id string) error {
+	if !m.Exist(id) {
+		return errors.New("no such document")
+	}
+	m.snippets[id].Meta.Archived = now()
+	return nil
+}
+
+// Restore clears the deleted mark
+func (m *MemStore) Restore(id string) error {
+	s, ok := m.snippets[id]
+	if !ok || s.Meta.Archived == nil {
+		return errors.New("no such archived document")
+	}
+	s.Meta.Archived = nil
+	return nil
+}
+
+// Delete removes the snippet
+func (m *MemStore) Delete(id string) error {
+	if _, ok := m.snippets[id]; !ok {
+		return errors.New("no such document")
+	}
+	delete(m.snippets, id)
+	delete(m.files, id)
+	return nil
+}
+
+// Attach stores a copy of the file with the snippet
+func (m *MemStore) Attach(id string, a *Attachment) error {
+	if !validAttachmentName(a.Name) {
+		return badAttachmentName(a.Name)
+	}
+	if !m.Exist(id) {
+		return errors.New("no such document")
+	}
+
+	if m.files[id] == nil {
+		m.files[id] = map[string]*Attachment{}
+	}
+	c := copyAttachment(a)
+	if c.Mode = c.Mode.Perm(); c.Mode == 0 {
+		c.Mode = 0644
+	}
+	m.files[id][a.Name] = c
+	m.snippets[id].Touch()
+	return nil
+}
+
+// Detach removes the file from the snippet
+func (m *MemStore) Detach(id, name string) error {
+	if !m.Exist(id) {
+		return errors.New("no such document")
+	}
+	if _, ok := m.files[id][name]; !ok {
+		return fmt.Errorf("no file %s in snippet", name)
+	}
+	delete(m.files[id], name)
+	m.snippets[id].Touch()
+	return nil
+}
+
+// Attachment returns a copy of the file
+func (m *MemStore) Attachment(id, name string) (*Attachment, error) {
+	a, ok := m.files[id][name]
+	if !ok {
+		return nil, fmt.Errorf("no file %s in snippet", name)
+	}
+	return copyAttachment(a), nil
+}
@@ -0,0 +1,313 @@
+package pipetdata
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// OutputFormats are the formats WriteRecords can produce
+var OutputFormats = []string{"json", "yaml", "csv", "tsv"}
+
+// Record is the stable, machine readable form of a snippet. Every key is
+// always present, unset times are null, Body only when it was asked for and
+// Files only when the snippet has attachments.
+type Record struct {
+	UID           string     `json:"uid" yaml:"uid"`
+	Title         string     `json:"title" yaml:"title"`
+	Tags          []string   `json:"tags" yaml:"tags"`
+	Created       *time.Time `json:"created" yaml:"created"`
+	Updated       *time.Time `json:"updated" yaml:"updated"`
+	Archived      *time.Time `json:"archived" yaml:"archived"`
+	FormatVersion int        `json:"format_version" yaml:"format_version"`
+	Extra         orderedMap `json:"extra" yaml:"extra"`
+	Files         []string   `json:"files,omitempty" yaml:"files,omitempty"`
+	Body          *string    `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// NewRecord converts a snippet, the body is included if body is set.
+func NewRecord(s *Snippet, body bool) Record {
+	r := Record{
+		UID:           s.Meta.UID,
+		Title:         s.Meta.Title,
+		Tags:          append([]string{}, s.Meta.Tags...),
+		Created:       s.Meta.Created,
+		Updated:       s.Meta.Updated,
+		Archived:      s.Meta.Archived,
+		FormatVersion: s.Meta.FormatVersion,
+		Extra:         toOrderedMap(s.Meta.Extra),
+		Files:         s.Meta.Files,
+	}
+	if body {
+		data := s.Data
+		r.Body = &data
+	}
+	return r
+}
+
+// Snippet converts the record back into a snippet, Data is empty if the
+// record has no body.
+func (r Record) Snippet() *Snippet {
+	s := &Snippet{Meta: metadata{
+		UID:           r.UID,
+		Title:         r.Title,
+		Tags:          r.Tags,
+		Created:       r.Created,
+		Updated:       r.Updated,
+		Archived:      r.Archived,
+		FormatVersion: r.FormatVersion,
+	}}
+	if len(r.Extra) > 0 {
+		s.Meta.Extra = yamlValue(r.Extra).(yaml.MapSlice)
+	}
+	if r.Body != nil {
+		s.Data = *r.Body
+	}
+	return s
+}
+
+// orderedMap is a yaml mapping that keeps its order in json
+type orderedMap yaml.MapSlice
+
+func toOrderedMap(ms yaml.MapSlice) orderedMap {
+	m := orderedMap{}
+	for _, item := range ms {
+		m = append(m, yaml.MapItem{Key: fmt.Sprint(item.Key), Value: jsonValue(item.Value)})
+	}
+	return m
+}
+
+// jsonValue converts decoded yaml into something encoding/json can render.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		return toOrderedMap(v)
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = jsonValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, item := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m orderedMap) MarshalYAML() (interface{}, error) {
+	return yaml.MapSlice(m), nil
+}
+
+func (m *orderedMap) UnmarshalJSON(buf []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		*m = nil
+	case orderedMap:
+		*m = v
+	default:
+		return fmt.Errorf("expected an object, got %T", v)
+	}
+	return nil
+}
+
+// decodeJSON decodes the next json value keeping the order of objects, numbers
+// become int or float64 like they would coming out of yaml.
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			m := orderedMap{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, yaml.MapItem{Key: key, Value: v})
+			}
+			_, err := dec.Token()
+			return m, err
+		}
+
+		l := []interface{}{}
+		for dec.More() {
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		_, err := dec.Token()
+		return l, err
+
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return int(i), nil
+		}
+		return t.Float64()
+	}
+	return tok, nil
+}
+
+// yamlValue undoes jsonValue
+func yamlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case orderedMap:
+		m := yaml.MapSlice{}
+		for _, item := range v {
+			m = append(m, yaml.MapItem{Key: item.Key, Value: yamlValue(item.Value)})
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = yamlValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+// WriteRecords renders snippets in one of OutputFormats. json and yaml give a
+// list of records, csv and tsv a header row followed by one row per snippet.
+func WriteRecords(w io.Writer, format string, sns []*Snippet, body bool) error {
+	recs := []Record{}
+	for _, s := range sns {
+		recs = append(recs, NewRecord(s, body))
+	}
+
+	switch format {
+	case "json":
+		return writeJSON(w, recs)
+	case "yaml":
+		return writeYAML(w, recs)
+	case "csv":
+		return writeTable(w, ',', recs, body)
+	case "tsv":
+		return writeTable(w, '\t', recs, body)
+	}
+	return unknownFormat(format)
+}
+
+// WriteRecord renders a single snippet, json and yaml give a single record
+// instead of a list.
+func WriteRecord(w io.Writer, format string, s *Snippet, body bool) error {
+	switch format {
+	case "json":
+		return writeJSON(w, NewRecord(s, body))
+	case "yaml":
+		return writeYAML(w, NewRecord(s, body))
+	}
+	return WriteRecords(w, format, []*Snippet{s}, body)
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown output format %q, use one of %s", format, strings.Join(OutputFormats, ", "))
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeYAML(w io.Writer, v interface{}) error {
+	buf, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// tableColumns is the header of csv and tsv output, tags are joined with
+// commas and extra fields are a json object.
+var tableColumns = []string{"uid", "title", "tags", "created", "updated", "archived", "format_version", "extra"}
+
+func writeTable(w io.Writer, sep rune, recs []Record, body bool) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = sep
+
+	header := tableColumns
+	if body {
+		header = append(header[:len(header):len(header)], "body")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range recs {
+		extra, err := json.Marshal(r.Extra)
+		if err != nil {
+			return err
+		}
+
+		row := []string{r.UID, r.Title, strings.Join(r.Tags, ","), timeColumn(r.Created),
+			timeColumn(r.Updated), timeColumn(r.Archived), strconv.Itoa(r.FormatVersion), string(extra)}
+		if body {
+			row = append(row, *r.Body)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func timeColumn(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
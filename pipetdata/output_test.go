@@ -0,0 +1,87 @@
+package pipetdata
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func outputSnippets() []*Snippet {
+	created := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	return []*Snippet{
+		{Meta: metadata{UID: "a.txt", Title: "Kernel version", Tags: []string{"linux", "kernel"},
+			Created: &created, Updated: &created, FormatVersion: formatVersion,
+			Extra: yaml.MapSlice{{Key: "owner", Value: "ops"}, {Key: "ticket", Value: 12}}},
+			Data: "uname -a\n"},
+		{Meta: metadata{UID: "b.txt", Title: "Say \"hi\""}, Data: "echo\thi\n"},
+	}
+}
+
+func TestWriteRecordsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteRecords(&buf, "json", outputSnippets()[:1], false), "json output")
+	assert.Equal(t, `[
+  {
+    "uid": "a.txt",
+    "title": "Kernel version",
+    "tags": [
+      "linux",
+      "kernel"
+    ],
+    "created": "2018-05-01T10:00:00Z",
+    "updated": "2018-05-01T10:00:00Z",
+    "archived": null,
+    "format_version": 1,
+    "extra": {
+      "owner": "ops",
+      "ticket": 12
+    }
+  }
+]
+`, buf.String(), "json schema")
+
+	buf.Reset()
+	assert.Nil(t, WriteRecord(&buf, "json", outputSnippets()[1], true), "single json record")
+	assert.Contains(t, buf.String(), `"tags": [],`, "no tags is an empty list")
+	assert.Contains(t, buf.String(), `"extra": {},`, "no extra fields is an empty object")
+	assert.Contains(t, buf.String(), `"body": "echo\thi\n"`, "body when asked for")
+}
+
+func TestWriteRecordsYAML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteRecord(&buf, "yaml", outputSnippets()[0], true), "yaml output")
+	assert.Equal(t, `uid: a.txt
+title: Kernel version
+tags:
+- linux
+- kernel
+created: 2018-05-01T10:00:00Z
+updated: 2018-05-01T10:00:00Z
+archived: null
+format_version: 1
+extra:
+  owner: ops
+  ticket: 12
+body: |
+  uname -a
+`, buf.String(), "yaml schema")
+}
+
+func TestWriteRecordsTable(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteRecords(&buf, "csv", outputSnippets(), false), "csv output")
+	assert.Equal(t, `uid,title,tags,created,updated,archived,format_version,extra
+a.txt,Kernel version,"linux,kernel",2018-05-01T10:00:00Z,2018-05-01T10:00:00Z,,1,"{""owner"":""ops"",""ticket"":12}"
+b.txt,"Say ""hi""",,,,,0,{}
+`, buf.String(), "csv rows")
+
+	buf.Reset()
+	assert.Nil(t, WriteRecords(&buf, "tsv", outputSnippets()[1:], true), "tsv output")
+	assert.Equal(t, "uid\ttitle\ttags\tcreated\tupdated\tarchived\tformat_version\textra\tbody\n"+
+		"b.txt\t\"Say \"\"hi\"\"\"\t\t\t\t\t0\t{}\t\"echo\thi\n\"\n", buf.String(), "tsv rows")
+
+	assert.NotNil(t, WriteRecords(&buf, "xml", outputSnippets(), false), "unknown format")
+}
@@ -0,0 +1,177 @@
+package pipetdata
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"gopkg.in/yaml.v2"
+)
+
+// pet (github.com/knqyf263/pet) keeps snippets in a toml file as
+//
+//	[[snippets]]
+//	  description = "Kernel version"
+//	  command = "uname -a"
+//	  tag = ["linux"]
+//	  output = ""
+//
+// description, command and tag map to title, body and tags, every other key
+// is kept as an extra field.
+var petFields = map[string]bool{"description": true, "command": true, "tag": true}
+
+// petNamespace seeds uids of imported pet snippets, importing the same file
+// twice gives the same uids so Import can tell the snippets are already there.
+var petNamespace = uuid.NewV5(uuid.NamespaceURL, "https://github.com/knqyf263/pet")
+
+// ReadPet reads a pet snippet file
+func ReadPet(r io.Reader) ([]*Snippet, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := toml.LoadBytes(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a pet snippet file")
+	}
+
+	sns := []*Snippet{}
+	entries, ok := tree.Get("snippets").([]*toml.Tree)
+	if !ok {
+		if tree.Has("snippets") {
+			return nil, errors.New("snippets is not an array of tables")
+		}
+		return sns, nil
+	}
+
+	for i, e := range entries {
+		title, _ := e.Get("description").(string)
+		command, _ := e.Get("command").(string)
+		s := &Snippet{
+			Meta: metadata{
+				UID:   fmt.Sprintf("%s.txt", uuid.NewV5(petNamespace, title+"\x00"+command)),
+				Title: title,
+			},
+			Data: command,
+		}
+
+		switch tags := e.Get("tag").(type) {
+		case nil:
+		case []interface{}:
+			for _, t := range tags {
+				s.Meta.Tags = append(s.Meta.Tags, fmt.Sprint(t))
+			}
+		case string:
+			s.Meta.Tags = strings.Fields(tags)
+		default:
+			return nil, fmt.Errorf("snippet %d: tag should be a list of strings", i+1)
+		}
+
+		for _, key := range tomlKeys(e) {
+			if !petFields[key] {
+				s.Meta.Extra = append(s.Meta.Extra, yaml.MapItem{Key: key, Value: fromTOML(e.Get(key))})
+			}
+		}
+		sns = append(sns, s)
+	}
+	return sns, nil
+}
+
+// tomlKeys returns the keys of a table in the order they appear in the file
+func tomlKeys(t *toml.Tree) []string {
+	keys := t.Keys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := t.GetPosition(keys[i]), t.GetPosition(keys[j])
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return keys
+}
+
+// fromTOML converts a toml value into what yaml would have decoded
+func fromTOML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		return int(v)
+	case *toml.Tree:
+		m := yaml.MapSlice{}
+		for _, key := range tomlKeys(v) {
+			m = append(m, yaml.MapItem{Key: key, Value: fromTOML(v.Get(key))})
+		}
+		return m
+	case []*toml.Tree:
+		l := []interface{}{}
+		for _, t := range v {
+			l = append(l, fromTOML(t))
+		}
+		return l
+	case []interface{}:
+		l := []interface{}{}
+		for _, e := range v {
+			l = append(l, fromTOML(e))
+		}
+		return l
+	}
+	return v
+}
+
+// toTOML converts an extra field value into something go-toml can write
+func toTOML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		m := map[string]interface{}{}
+		for _, item := range v {
+			if item.Value != nil {
+				m[fmt.Sprint(item.Key)] = toTOML(item.Value)
+			}
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			if e != nil {
+				m[fmt.Sprint(k)] = toTOML(e)
+			}
+		}
+		return m
+	case []interface{}:
+		l := []interface{}{}
+		for _, e := range v {
+			l = append(l, toTOML(e))
+		}
+		return l
+	}
+	return v
+}
+
+// WritePet writes snippets as a pet snippet file, extra fields are written as
+// additional keys.
+func WritePet(w io.Writer, sns []*Snippet) error {
+	entries := []map[string]interface{}{}
+	for _, s := range sns {
+		e := map[string]interface{}{}
+		for _, item := range s.Meta.Extra {
+			if key := fmt.Sprint(item.Key); !petFields[key] && item.Value != nil {
+				e[key] = toTOML(item.Value)
+			}
+		}
+		e["description"] = s.Meta.Title
+		e["command"] = strings.TrimSuffix(s.Data, "\n")
+		e["tag"] = append([]string{}, s.Meta.Tags...)
+		entries = append(entries, e)
+	}
+
+	tree, err := toml.TreeFromMap(map[string]interface{}{"snippets": entries})
+	if err != nil {
+		return errors.Wrap(err, "rendering toml failed")
+	}
+	_, err = tree.WriteTo(w)
+	return err
+}
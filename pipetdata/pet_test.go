@@ -0,0 +1,79 @@
+package pipetdata
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+var petFile = `[[snippets]]
+  description = "Kernel version"
+  command = "uname -a"
+  tag = ["linux", "kernel"]
+  output = "Linux box 4.15.0"
+
+[[snippets]]
+  description = "Restart web"
+  command = """
+kubectl rollout restart deployment/web
+kubectl rollout status deployment/web"""
+  tag = []
+  output = ""
+  owner = "ops"
+  [snippets.source]
+    url = "https://wiki/restart"
+    reviewed = 2
+`
+
+func TestReadPet(t *testing.T) {
+	sns, err := ReadPet(strings.NewReader(petFile))
+	assert.Nil(t, err, "should parse")
+	assert.Len(t, sns, 2, "two snippets")
+
+	assert.Equal(t, "Kernel version", sns[0].Meta.Title, "description is the title")
+	assert.Equal(t, "uname -a", sns[0].Data, "command is the body")
+	assert.Equal(t, []string{"linux", "kernel"}, sns[0].Meta.Tags, "tags")
+	assert.Equal(t, yaml.MapSlice{{Key: "output", Value: "Linux box 4.15.0"}}, sns[0].Meta.Extra, "output is kept")
+
+	assert.Equal(t, "kubectl rollout restart deployment/web\nkubectl rollout status deployment/web",
+		sns[1].Data, "multi line command")
+	assert.Equal(t, yaml.MapSlice{
+		{Key: "output", Value: ""},
+		{Key: "owner", Value: "ops"},
+		{Key: "source", Value: yaml.MapSlice{{Key: "url", Value: "https://wiki/restart"}, {Key: "reviewed", Value: 2}}},
+	}, sns[1].Meta.Extra, "unknown keys are kept in order")
+
+	again, err := ReadPet(strings.NewReader(petFile))
+	assert.Nil(t, err, "should parse")
+	assert.Equal(t, sns[0].Meta.UID, again[0].Meta.UID, "uids are stable across imports")
+	assert.NotEqual(t, sns[0].Meta.UID, sns[1].Meta.UID, "uids differ between snippets")
+
+	_, err = ReadPet(strings.NewReader("snippets = 1"))
+	assert.NotNil(t, err, "snippets must be tables")
+	_, err = ReadPet(strings.NewReader("[[snippets]"))
+	assert.NotNil(t, err, "not toml")
+}
+
+func TestWritePet(t *testing.T) {
+	sns, err := ReadPet(strings.NewReader(petFile))
+	assert.Nil(t, err, "should parse")
+	sns[0].Data += "\n"
+
+	var buf bytes.Buffer
+	assert.Nil(t, WritePet(&buf, sns), "should render")
+
+	again, err := ReadPet(&buf)
+	assert.Nil(t, err, "rendered file should parse")
+	assert.Len(t, again, 2, "two snippets")
+	for i := range sns {
+		assert.Equal(t, sns[i].Meta.UID, again[i].Meta.UID, "same snippet")
+		assert.Equal(t, sns[i].Meta.Tags, again[i].Meta.Tags, "tags round trip")
+		assert.Equal(t, strings.TrimSuffix(sns[i].Data, "\n"), again[i].Data, "command round trips")
+		assert.Equal(t, len(sns[i].Meta.Extra), len(again[i].Meta.Extra), "extra fields round trip")
+	}
+	owner, _ := again[1].Field("owner")
+	assert.Equal(t, "ops", owner, "extra field value")
+}
@@ -3,15 +3,15 @@
 package pipetdata
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/pkg/errors"
-	"github.com/satori/go.uuid"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 var (
@@ -19,16 +19,80 @@ var (
 	EBadData = fmt.Errorf("bad data")
 )
 
-// DataStore is the main structure for snippet access
+// ListError is returned by List when some snippets couldn't be read, the ones
+// that could are returned along with it.
+type ListError struct {
+	Errs map[string]error // keyed by uid
+}
+
+func (e *ListError) Error() string {
+	for id, err := range e.Errs {
+		if len(e.Errs) == 1 {
+			return fmt.Sprintf("reading %s failed: %v", id, err)
+		}
+		return fmt.Sprintf("%d snippets could not be read, %s: %v", len(e.Errs), id, err)
+	}
+	return "no errors"
+}
+
+const (
+	// trashDir holds archived snippets, relative to documentDir
+	trashDir = ".trash"
+	// tmpPrefix marks temporary files written by writeFileAtomic
+	tmpPrefix = ".pipet-tmp-"
+)
+
+// DataStore is the directory backed Store, each snippet is a file named by its
+// uid inside documentDir. Archived snippets are moved to documentDir/.trash.
 type DataStore struct {
 	documentDir string
 }
 
+// formatVersion is the current layout of snippet metadata. Files written
+// before format_version existed are version 0, they are upgraded the next time
+// they are written.
+const formatVersion = 1
+
 // Metadata for snippet
 type metadata struct {
-	UID   string   // only relevant to data store, pointer to file where snippet is stored.
-	Title string   `yaml:"title"`
-	Tags  []string `yaml:"tags,omitempty"`
+	UID           string     // only relevant to data store, pointer to file where snippet is stored.
+	Title         string     `yaml:"title"`
+	Tags          []string   `yaml:"tags,omitempty"`
+	Created       *time.Time `yaml:"created,omitempty"`
+	Updated       *time.Time `yaml:"updated,omitempty"`
+	Archived      *time.Time `yaml:"archived,omitempty"` // set when the snippet is in the trash
+	FormatVersion int        `yaml:"format_version,omitempty"`
+
+	// Files names the snippet's attachments, filled in by stores that are
+	// Attachers. It isn't part of the front matter.
+	Files []string `yaml:"-"`
+
+	// Extra holds front matter keys pipet doesn't know about, in the order
+	// they appear in the file. They are written back after the known keys.
+	Extra yaml.MapSlice `yaml:"-"`
+	raw   map[string]rawField
+}
+
+func now() *time.Time {
+	t := time.Now().Truncate(time.Second)
+	return &t
+}
+
+// newMetadata is the metadata of a freshly created snippet
+func newMetadata(uid, title string, tags []string) metadata {
+	t := now()
+	return metadata{UID: uid, Title: title, Tags: tags, Created: t, Updated: t,
+		FormatVersion: formatVersion}
+}
+
+// upgrade brings metadata written by an older pipet up to formatVersion, since
+// is the best guess of when such a snippet was created.
+func (m *metadata) upgrade(since time.Time) {
+	if m.FormatVersion < 1 && m.Created == nil {
+		since = since.Truncate(time.Second)
+		m.Created = &since
+	}
+	m.FormatVersion = formatVersion
 }
 
 // Snippet is the data type holding the actual snippet
@@ -37,60 +101,84 @@ type Snippet struct {
 	Data string
 }
 
+// Touch marks the snippet as modified now, callers changing a snippet should
+// call it before writing it back.
+func (s *Snippet) Touch() {
+	s.Meta.Updated = now()
+}
+
 // Marshal serializes snippet data into bytes. Format is
 // ---
 // yaml metadata front
 // ---
 // <text follows>
 // This is very similiar to pandoc markdown except its just arbitary text for now.
+// The front matter is yaml unless another format is set with SetFrontMatter.
+// A trailing newline is added to the text unless it already has one, so
+// Marshal and Unmarshal round trip.
 func (s *Snippet) Marshal() ([]byte, error) {
+	return s.marshalAs(frontMatter)
+}
+
+// marshalAs is Marshal with front matter in format
+func (s *Snippet) marshalAs(format string) ([]byte, error) {
 	template := `---
 %s---
-%s
-`
-	meta, err := yaml.Marshal(s.Meta)
+%s`
+	var meta []byte
+	var err error
+	switch format {
+	case "toml":
+		template = "+++\n%s+++\n%s"
+		meta, err = s.Meta.marshalTOML()
+	case "json":
+		template = "%s%s"
+		meta, err = s.Meta.marshalJSON()
+	default:
+		meta, err = s.Meta.marshalYAML()
+	}
 	if err != nil {
-		return []byte{}, errors.Wrap(err, "yaml rendering failed")
+		return []byte{}, errors.Wrapf(err, "%s rendering failed", format)
 	}
 
-	rendered := fmt.Sprintf(template, meta, s.Data)
+	data := s.Data
+	if !strings.HasSuffix(data, "\n") {
+		data += "\n"
+	}
+
+	rendered := fmt.Sprintf(template, meta, data)
 	return []byte(rendered), nil
 }
 
-func splitData(buf []byte) (front, data []byte, err error) {
-	guard := []byte("---")
-	lg := len(guard)
-
-	if !bytes.HasPrefix(buf, guard) {
-		err = EBadData
-		return
+func (m *metadata) marshalYAML() ([]byte, error) {
+	meta, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
 	}
 
-	buf = buf[lg:]
-
-	end := bytes.Index(buf, guard)
-	if end == -1 {
-		err = EBadData
-		return
+	extra, err := m.marshalExtra()
+	if err != nil {
+		return nil, err
 	}
-
-	front = buf[0:end]
-	// skip --- + '\n'
-	data = buf[end+lg+1:]
-	return
+	return append(meta, extra...), nil
 }
 
-// Unmarshal takes in note data with metadata blocks in yaml and populates
-// Snippet structure.
+// Unmarshal takes in note data with metadata blocks in yaml, toml or json and
+// populates Snippet structure.
 // https://jekyllrb.com/docs/frontmatter/
 func (s *Snippet) Unmarshal(buf []byte) error {
-	front, data, err := splitData(buf)
+	format, front, data, err := splitData(buf)
+	if err != nil {
+		return err
+	}
 
-	var meta metadata
-	yaml.Unmarshal(front, &meta)
+	meta, err := parseFront(format, front)
+	if err != nil {
+		return err
+	}
 	s.Meta = meta
 	s.Data = string(data)
-	return err
+	return nil
 }
 
 // NewDataStore creates a new datastore abastraction for storing notes. disk
@@ -112,47 +200,177 @@ func NewDataStore(documentDir string) (*DataStore, error) {
 
 // Exist checks with a snippet with the name exists.
 func (d *DataStore) Exist(filename string) bool {
+	if !validID(filename) {
+		return false
+	}
 	fullpath := filepath.Join(d.documentDir, filename)
 	_, err := os.Stat(fullpath)
 	return err == nil
 }
 
+// Fullpath returns the path to the file holding the snippet, the manifest
+// for directory snippets.
 func (d *DataStore) Fullpath(id string) string {
+	return snippetFile(d.path(id))
+}
+
+// path is where the snippet lives, a file or a directory snippet
+func (d *DataStore) path(id string) string {
 	return filepath.Join(d.documentDir, id)
 }
 
-// New creates a new entry in snippets
-func (d *DataStore) New(title string, tags ...string) (fn string, err error) {
-	id := uuid.NewV4().String()
-	uid := fmt.Sprintf("%s.txt", id)
+// locked runs fn while holding a lock on documentDir, so other pipet processes
+// don't change the store underneath it.
+func (d *DataStore) locked(fn func() error) error {
+	unlock, err := lockFile(d.documentDir)
+	if err != nil {
+		return errors.Wrap(err, "locking store failed")
+	}
+	defer unlock()
+	return fn()
+}
+
+// New creates a new entry in snippets and returns its uid
+func (d *DataStore) New(title string, tags ...string) (uid string, err error) {
+	err = d.locked(func() error {
+		uid, err = d.create(title, tags...)
+		return err
+	})
+	return
+}
+
+func (d *DataStore) create(title string, tags ...string) (string, error) {
+	uid := newID()
 
 	ns := &Snippet{
-		Meta: metadata{uid, title, tags},
+		Meta: newMetadata(uid, title, tags),
 	}
 
 	if d.Exist(uid) {
 		return "", errors.New("duplicate snippet")
 	}
 
-	data, err := ns.Marshal()
-	if err != nil {
-		return "", errors.Wrap(err, "marshalling failed")
+	return uid, d.write(ns)
+}
+
+func (d *DataStore) trashpath(id string) string {
+	return filepath.Join(d.documentDir, trashDir, id)
+}
+
+// Write renders the snippet to its file, replacing existing content. Archived
+// snippets are written to the trash.
+func (d *DataStore) Write(s *Snippet) error {
+	return d.locked(func() error {
+		return d.write(s)
+	})
+}
+
+func (d *DataStore) write(s *Snippet) error {
+	uid := s.Meta.UID
+	if !validID(uid) {
+		return fmt.Errorf("invalid snippet uid: %q", uid)
 	}
 
 	filename := d.Fullpath(uid)
-	err = ioutil.WriteFile(filename, data, 0755)
-	return filename, err
+	if s.Meta.Archived != nil {
+		filename = snippetFile(d.trashpath(uid))
+	}
+
+	// the snippet may be on its way in or out of the trash
+	existing := []string{d.Fullpath(uid), snippetFile(d.trashpath(uid))}
+
+	if s.Meta.FormatVersion < formatVersion {
+		// the file's mtime is all we know about the age of an old snippet
+		since := time.Now()
+		for _, f := range existing {
+			if fi, err := os.Stat(f); err == nil {
+				since = fi.ModTime()
+				break
+			}
+		}
+		c := *s
+		c.Meta.upgrade(since)
+		s = &c
+	}
+
+	// files keep their front matter format, they may belong to another tool
+	data, err := s.marshalAs(fileFrontMatter(existing...))
+	if err != nil {
+		return errors.Wrap(err, "marshalling failed")
+	}
+
+	if s.Meta.Archived != nil {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return errors.Wrap(err, "creating trash failed")
+		}
+		return writeFileAtomic(filename, data, 0755)
+	}
+
+	if err := writeFileAtomic(filename, data, 0755); err != nil {
+		return err
+	}
+	// the index is only a cache, List repairs it if this fails
+	d.indexFile(s, data)
+	return nil
 }
 
-// Read reads and parses a snippet document
+// writeFileAtomic writes data to a temporary file next to filename and renames
+// it into place, readers see either the old or the new content but never a
+// partial write.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), tmpPrefix)
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file failed")
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "writing failed")
+	}
+	return nil
+}
+
+// validID rejects uids that can't be used as a file name in documentDir.
+func validID(id string) bool {
+	return strings.HasSuffix(id, ".txt") && filepath.Base(id) == id &&
+		!strings.HasPrefix(id, ".")
+}
+
+// Read reads and parses a snippet document, looking in the trash if there is
+// no such live snippet.
 func (d *DataStore) Read(id string) (sn *Snippet, err error) {
-	if !d.Exist(id) {
-		err = errors.New("no such document")
-		return
+	if !validID(id) {
+		return nil, errors.New("no such document")
+	}
+	if d.Exist(id) {
+		return readSnippet(d.path(id))
 	}
 
-	filename := d.Fullpath(id)
+	if _, e := os.Stat(d.trashpath(id)); e == nil {
+		return readSnippet(d.trashpath(id))
+	}
 
+	err = errors.New("no such document")
+	return
+}
+
+// readSnippet reads the snippet at path, a file or a directory snippet
+func readSnippet(path string) (sn *Snippet, err error) {
+	filename := snippetFile(path)
 	buf, err := ioutil.ReadFile(filename)
 	if err != nil {
 		err = errors.Wrap(err, "reading failed")
@@ -160,46 +378,193 @@ func (d *DataStore) Read(id string) (sn *Snippet, err error) {
 	}
 
 	s := &Snippet{}
-	err = s.Unmarshal(buf)
+	if err = s.Unmarshal(buf); err != nil {
+		return s, err
+	}
+	if filename != path {
+		s.Meta.Files, err = dirFiles(path)
+	}
 	return s, err
 }
 
+// List returns the metadata of every live snippet in documentDir, Data is
+// left empty. Metadata is served from an index that is refreshed for files
+// changed since the last List.
 func (d *DataStore) List() (sns []*Snippet, err error) {
+	sns, err = d.listIndexed()
+	if err == nil && len(sns) == 0 {
+		err = ErrEmptyStore
+	}
+	return
+}
+
+// Archived reads every snippet in the trash.
+func (d *DataStore) Archived() ([]*Snippet, error) {
+	sns, err := readSnippetDir(filepath.Join(d.documentDir, trashDir))
+	if os.IsNotExist(err) {
+		return sns, nil
+	}
+	return sns, err
+}
+
+// readSnippetDir reads all snippets in dir, snippets that fail to parse are
+// skipped and reported in a *ListError.
+func readSnippetDir(dir string) (sns []*Snippet, err error) {
 	sns = []*Snippet{}
 
-	fli, err := ioutil.ReadDir(d.documentDir)
+	fli, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return
 	}
 
+	lerr := &ListError{Errs: map[string]error{}}
 	for _, f := range fli {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".txt") {
-			s, e := d.Read(f.Name())
+		if _, e := statSnippet(filepath.Join(dir, f.Name()), f); e == nil && validID(f.Name()) {
+			s, e := readSnippet(filepath.Join(dir, f.Name()))
 			if e != nil {
-				err = e
-				return
+				lerr.Errs[f.Name()] = e
+				continue
 			}
 			sns = append(sns, s)
 		}
 	}
 
-	if len(sns) == 0 {
-		err = errors.New("empty snippet store")
+	if len(lerr.Errs) > 0 {
+		err = lerr
 	}
 	return
 }
 
-func (d *DataStore) Delete(id string) error {
+// Archive moves the snippet into the trash, recording when it was deleted.
+func (d *DataStore) Archive(id string) error {
+	return d.locked(func() error {
+		return d.archive(id)
+	})
+}
+
+func (d *DataStore) archive(id string) error {
 	if !d.Exist(id) {
 		return errors.New("no such document")
 	}
 
-	filename := d.Fullpath(id)
+	s, err := readSnippet(d.path(id))
+	if err != nil {
+		return err
+	}
+	s.Meta.Archived = now()
+
+	if isDirSnippet(d.path(id)) {
+		// directory snippets move as a whole, the manifest is stamped there
+		if err := d.moveDir(d.path(id), d.trashpath(id)); err != nil {
+			return errors.Wrap(err, "archiving failed")
+		}
+		d.unindexFile(id)
+		return d.write(s)
+	}
+
+	if err := d.write(s); err != nil {
+		return errors.Wrap(err, "archiving failed")
+	}
+	return d.remove(id)
+}
+
+// moveDir moves a directory snippet in or out of the trash
+func (d *DataStore) moveDir(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(to); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// Restore moves an archived snippet back out of the trash.
+func (d *DataStore) Restore(id string) error {
+	return d.locked(func() error {
+		return d.restore(id)
+	})
+}
+
+func (d *DataStore) restore(id string) error {
+	s, err := d.Read(id)
+	if err != nil {
+		return err
+	}
+	if s.Meta.Archived == nil {
+		return errors.New("snippet is not archived")
+	}
+
+	s.Meta.Archived = nil
+
+	if isDirSnippet(d.trashpath(id)) {
+		if err := d.moveDir(d.trashpath(id), d.path(id)); err != nil {
+			return errors.Wrap(err, "restoring failed")
+		}
+		return d.write(s)
+	}
+
+	if err := d.write(s); err != nil {
+		return errors.Wrap(err, "restoring failed")
+	}
+	return os.Remove(d.trashpath(id))
+}
+
+// Delete removes the snippet file, or directory, for good, live or archived.
+func (d *DataStore) Delete(id string) error {
+	return d.locked(func() error {
+		return d.remove(id)
+	})
+}
+
+func (d *DataStore) remove(id string) error {
+	if !validID(id) {
+		return errors.New("no such document")
+	}
+	filename := d.path(id)
+	if !d.Exist(id) {
+		filename = d.trashpath(id)
+		if _, err := os.Stat(filename); err != nil {
+			return errors.New("no such document")
+		}
+	}
 
-	err := os.Remove(filename)
+	err := os.RemoveAll(filename)
 	if err != nil {
 		return errors.Wrap(err, "delete failed")
 	}
 
+	d.unindexFile(id)
 	return nil
 }
+
+// SortKeys are the orders SortSnippets knows about
+var SortKeys = []string{"title", "created", "updated"}
+
+// SortSnippets orders sns by title, or newest first by created or updated
+// time. Snippets without a timestamp sort last.
+func SortSnippets(sns []*Snippet, key string) error {
+	var less func(a, b *Snippet) bool
+	switch key {
+	case "title":
+		less = func(a, b *Snippet) bool {
+			return strings.ToLower(a.Meta.Title) < strings.ToLower(b.Meta.Title)
+		}
+	case "created":
+		less = func(a, b *Snippet) bool { return newer(a.Meta.Created, b.Meta.Created) }
+	case "updated":
+		less = func(a, b *Snippet) bool { return newer(a.Meta.Updated, b.Meta.Updated) }
+	default:
+		return fmt.Errorf("unknown sort order %q, use one of %s", key, strings.Join(SortKeys, ", "))
+	}
+
+	sort.SliceStable(sns, func(i, j int) bool { return less(sns[i], sns[j]) })
+	return nil
+}
+
+func newer(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a != nil
+	}
+	return a.After(*b)
+}
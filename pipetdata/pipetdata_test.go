@@ -1,10 +1,15 @@
 package pipetdata
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var exampleSnippet = []byte(`---
@@ -56,19 +61,22 @@ func TestDataStore(t *testing.T) {
 	assert.Nil(t, err, "new snippet must be created")
 
 	// try to read back file
-	fi, err := ioutil.ReadDir(tmpdir)
+	fi := snippetFiles(t, tmpdir)
 
 	assert.Len(t, fi, 1, "should be just one file")
 	ours := fi[0]
 	uid := ours.Name()
 
-	assert.Equal(t, fn, filepath.Join(tmpdir, uid), "filepaths should match")
+	assert.Equal(t, uid, fn, "uid should match file name")
+	assert.Equal(t, filepath.Join(tmpdir, uid), ds.Fullpath(uid), "filepaths should match")
 	// try to read back!
 	sn, err := ds.Read(ours.Name())
 	assert.Nil(t, err, "should be a valid snippet")
 
+	assert.NotNil(t, sn.Meta.Created, "creation time must be set")
 	expected := &Snippet{
-		Meta: metadata{UID: uid, Title: "Kernel version", Tags: []string{"linux", "kernel", "systems", "code"}},
+		Meta: metadata{UID: uid, Title: "Kernel version", Tags: []string{"linux", "kernel", "systems", "code"},
+			Created: sn.Meta.Created, Updated: sn.Meta.Created, FormatVersion: formatVersion},
 	}
 
 	assert.Equal(t, expected.Meta, sn.Meta, "snippet metadata should match")
@@ -106,8 +114,10 @@ func TestDataStoreList(t *testing.T) {
 	assert.Nil(t, err, "should not error")
 	assert.Len(t, snli, 1, "empty ds")
 
+	assert.NotNil(t, snli[0].Meta.Created, "creation time must be set")
 	expected := &Snippet{
-		Meta: metadata{UID: uid, Title: "Kernel version", Tags: []string{"linux", "kernel", "systems", "code"}},
+		Meta: metadata{UID: uid, Title: "Kernel version", Tags: []string{"linux", "kernel", "systems", "code"},
+			Created: snli[0].Meta.Created, Updated: snli[0].Meta.Created, FormatVersion: formatVersion},
 	}
 
 	assert.Equal(t, expected.Meta, snli[0].Meta, "metadata must match")
@@ -119,3 +129,203 @@ func TestDataStoreList(t *testing.T) {
 	assert.Nil(t, err, "should not error")
 	assert.Len(t, snli, 2, "empty ds")
 }
+
+func TestDataStoreConcurrentWrites(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := ds.New(fmt.Sprintf("snippet %d", i), "test")
+			assert.Nil(t, err, "new snippet must be created")
+		}(i)
+	}
+	wg.Wait()
+
+	snli, err := ds.List()
+	assert.Nil(t, err, "should not error")
+	assert.Len(t, snli, 20, "every snippet should be there")
+
+	// no temporary files are left behind
+	fi, err := ioutil.ReadDir(tmpdir)
+	assert.Nil(t, err, "reading store")
+	for _, f := range fi {
+		assert.False(t, strings.HasPrefix(f.Name(), tmpPrefix), "temporary file %s left behind", f.Name())
+	}
+	assert.Len(t, snippetFiles(t, tmpdir), 20, "only snippets should be in the store")
+}
+
+// snippetFiles lists dir without the hidden bookkeeping files
+func snippetFiles(t *testing.T, dir string) []os.FileInfo {
+	fi, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err, "reading store")
+
+	visible := []os.FileInfo{}
+	for _, f := range fi {
+		if !strings.HasPrefix(f.Name(), ".") {
+			visible = append(visible, f)
+		}
+	}
+	return visible
+}
+
+func TestDataStoreIndex(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	uid, err := ds.New("Kernel version", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+	other, err := ds.New("Uptime", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+
+	idx := ds.loadIndex()
+	assert.Len(t, idx.Entries, 2, "new snippets are indexed")
+	assert.Equal(t, "Kernel version", idx.Entries[uid].Meta.Title, "index holds metadata")
+	assert.NotEmpty(t, idx.Entries[uid].Hash, "index holds content hash")
+
+	// change a file behind pipet's back, with a different size
+	changed := []byte("---\nuid: " + uid + "\ntitle: Kernel release\n---\nuname -r\n")
+	assert.Nil(t, ioutil.WriteFile(ds.Fullpath(uid), changed, 0644), "external edit")
+
+	snli, err := ds.List()
+	assert.Nil(t, err, "should not error")
+	assert.Len(t, snli, 2, "both snippets listed")
+
+	titles := map[string]string{}
+	for _, s := range snli {
+		titles[s.Meta.UID] = s.Meta.Title
+		assert.Empty(t, s.Data, "list only carries metadata")
+	}
+	assert.Equal(t, "Kernel release", titles[uid], "changed file is re-read")
+	assert.Equal(t, "Kernel release", ds.loadIndex().Entries[uid].Meta.Title, "index is refreshed")
+
+	// a List that read the file before a concurrent write doesn't put its
+	// older entry back
+	listed := ds.loadIndex().Entries[uid]
+	sn, _ := ds.Read(uid)
+	sn.Meta.Title = "Kernel name"
+	assert.Nil(t, ds.Write(sn), "concurrent write")
+	assert.Nil(t, ds.updateIndex(map[string]*indexEntry{uid: listed}, map[string]string{uid: ""}), "update index")
+	assert.Equal(t, "Kernel name", ds.loadIndex().Entries[uid].Meta.Title, "newer entry is kept")
+
+	assert.Nil(t, ds.Delete(other), "delete should succeed")
+	_, ok := ds.loadIndex().Entries[other]
+	assert.False(t, ok, "deleted snippet is dropped from the index")
+
+	// a broken index is rebuilt
+	assert.Nil(t, ioutil.WriteFile(ds.indexPath(), []byte("{"), 0644), "corrupt index")
+	snli, err = ds.List()
+	assert.Nil(t, err, "should not error")
+	assert.Len(t, snli, 1, "snippet listed from a rebuilt index")
+	assert.Len(t, ds.loadIndex().Entries, 1, "index rebuilt")
+}
+
+func TestDataStoreUpgrade(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	// written by a pipet without timestamps
+	uid := "old.txt"
+	old := []byte("---\nuid: old.txt\ntitle: Kernel version\n---\nuname -a\n")
+	assert.Nil(t, ioutil.WriteFile(ds.Fullpath(uid), old, 0644), "old snippet")
+	mtime := time.Date(2017, 3, 1, 10, 0, 0, 0, time.Local)
+	assert.Nil(t, os.Chtimes(ds.Fullpath(uid), mtime, mtime), "backdate snippet")
+
+	sn, err := ds.Read(uid)
+	assert.Nil(t, err, "old snippet is readable")
+	assert.Equal(t, 0, sn.Meta.FormatVersion, "no format version")
+	assert.Nil(t, sn.Meta.Created, "no creation time")
+
+	buf, _ := ioutil.ReadFile(ds.Fullpath(uid))
+	assert.Equal(t, old, buf, "reading leaves the file alone")
+
+	sn.Touch()
+	assert.Nil(t, ds.Write(sn), "write should succeed")
+
+	sn, err = ds.Read(uid)
+	assert.Nil(t, err, "upgraded snippet is readable")
+	assert.Equal(t, formatVersion, sn.Meta.FormatVersion, "upgraded")
+	assert.True(t, mtime.Equal(*sn.Meta.Created), "creation time taken from the file")
+	assert.True(t, sn.Meta.Updated.After(mtime), "update time set")
+}
+
+func TestSortSnippets(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2018, 1, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	sns := []*Snippet{
+		{Meta: metadata{UID: "a.txt", Title: "uptime", Created: day(2), Updated: day(2)}},
+		{Meta: metadata{UID: "b.txt", Title: "Kernel", Created: day(1), Updated: day(3)}},
+		{Meta: metadata{UID: "c.txt", Title: "disk"}},
+	}
+	order := func() []string {
+		out := []string{}
+		for _, s := range sns {
+			out = append(out, s.Meta.UID)
+		}
+		return out
+	}
+
+	assert.Nil(t, SortSnippets(sns, "title"), "sort by title")
+	assert.Equal(t, []string{"c.txt", "b.txt", "a.txt"}, order(), "case insensitive title order")
+
+	assert.Nil(t, SortSnippets(sns, "created"), "sort by creation")
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, order(), "newest first, undated last")
+
+	assert.Nil(t, SortSnippets(sns, "updated"), "sort by update")
+	assert.Equal(t, []string{"b.txt", "a.txt", "c.txt"}, order(), "recently updated first")
+
+	assert.NotNil(t, SortSnippets(sns, "size"), "unknown order")
+}
+
+var extraSnippet = []byte(`---
+uid: ext.txt
+title: Restart web
+owner: ops
+ticket: 1234 # tracker
+source:
+  url: https://wiki/restart
+  # kept up to date by the ops team
+  checked: 2018-05-01
+---
+kubectl rollout restart deployment/web
+`)
+
+func TestExtraFields(t *testing.T) {
+	var snip Snippet
+	assert.Nil(t, snip.Unmarshal(extraSnippet), "should parse")
+	assert.Equal(t, "Restart web", snip.Meta.Title, "title should match")
+
+	owner, ok := snip.Field("owner")
+	assert.True(t, ok, "extra field is kept")
+	assert.Equal(t, "ops", owner, "extra value")
+	_, ok = snip.Field("title")
+	assert.False(t, ok, "known keys are not extra")
+
+	data, err := snip.Marshal()
+	assert.Nil(t, err, "should render")
+	assert.Equal(t, extraSnippet, data, "extra fields round trip in order")
+
+	assert.NotNil(t, snip.SetField("title", "x"), "known keys can't be set")
+	assert.Nil(t, snip.SetField("ticket", 4321), "change a field")
+	assert.Nil(t, snip.SetField("source", nil), "remove a field")
+	assert.Nil(t, snip.SetField("team", "infra"), "add a field")
+
+	data, err = snip.Marshal()
+	assert.Nil(t, err, "should render")
+	assert.Equal(t, "---\nuid: ext.txt\ntitle: Restart web\nowner: ops\nticket: 4321\nteam: infra\n---\n"+
+		"kubectl rollout restart deployment/web\n", string(data), "changed fields")
+}
@@ -0,0 +1,325 @@
+package pipetdata
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Query selects snippets. Queries are written like
+//
+//	tag:k8s AND (title:deploy OR body:kubectl) -tag:old
+//
+// Terms next to each other must all match, OR between them is enough for
+// either, NOT or a leading - negates and parentheses group. field:value terms
+// look at one field:
+//
+//	tag:x    a tag is x, tag:x* a tag starts with x
+//	title:x  the title contains x
+//	body:x   the body contains x
+//	uid:x    the uid starts with x
+//	key:x    the extra metadata field key contains x
+//
+// A bare word matches the title, a tag or the body. Matching ignores case,
+// values with spaces can be quoted: title:"disk usage".
+type Query interface {
+	// Match reports if the snippet is selected
+	Match(s *Snippet) bool
+	// NeedsBody reports if the query looks at the body, which List may leave
+	// out.
+	NeedsBody() bool
+}
+
+type andQuery []Query
+
+func (q andQuery) Match(s *Snippet) bool {
+	for _, sub := range q {
+		if !sub.Match(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q andQuery) NeedsBody() bool {
+	for _, sub := range q {
+		if sub.NeedsBody() {
+			return true
+		}
+	}
+	return false
+}
+
+type orQuery []Query
+
+func (q orQuery) Match(s *Snippet) bool {
+	for _, sub := range q {
+		if sub.Match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q orQuery) NeedsBody() bool {
+	return andQuery(q).NeedsBody()
+}
+
+type notQuery struct {
+	q Query
+}
+
+func (q notQuery) Match(s *Snippet) bool { return !q.q.Match(s) }
+func (q notQuery) NeedsBody() bool       { return q.q.NeedsBody() }
+
+type termQuery struct {
+	field string // empty for bare words
+	value string // lower case, fields are matched as written
+}
+
+func contains(s, sub string) bool {
+	return strings.Contains(strings.ToLower(s), sub)
+}
+
+func (q termQuery) Match(s *Snippet) bool {
+	switch strings.ToLower(q.field) {
+	case "":
+		return contains(s.Meta.Title, q.value) || contains(s.Data, q.value) ||
+			termQuery{"tag", q.value}.Match(s)
+	case "tag":
+		prefix := strings.HasSuffix(q.value, "*")
+		v := strings.TrimSuffix(q.value, "*")
+		for _, t := range s.Meta.Tags {
+			t = strings.ToLower(t)
+			if t == v || prefix && strings.HasPrefix(t, v) {
+				return true
+			}
+		}
+		return false
+	case "title":
+		return contains(s.Meta.Title, q.value)
+	case "body":
+		return contains(s.Data, q.value)
+	case "uid":
+		return strings.HasPrefix(strings.ToLower(s.Meta.UID), q.value)
+	}
+
+	v, ok := s.Field(q.field)
+	return ok && contains(fmt.Sprint(v), q.value)
+}
+
+func (q termQuery) NeedsBody() bool {
+	f := strings.ToLower(q.field)
+	return f == "" || f == "body"
+}
+
+// And selects snippets matching every query, all of them if there are none
+func And(qs ...Query) Query {
+	if len(qs) == 1 {
+		return qs[0]
+	}
+	return andQuery(qs)
+}
+
+// Not selects snippets q doesn't
+func Not(q Query) Query {
+	return notQuery{q}
+}
+
+// Term selects snippets whose field matches value, like field:value in a
+// query. An empty field is a bare word.
+func Term(field, value string) Query {
+	return termQuery{field, strings.ToLower(value)}
+}
+
+// FilterSnippets returns the snippets q selects
+func FilterSnippets(sns []*Snippet, q Query) []*Snippet {
+	out := []*Snippet{}
+	for _, s := range sns {
+		if q.Match(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// query tokens, anything else is a term
+const (
+	tokEnd = iota
+	tokTerm
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+)
+
+type queryToken struct {
+	kind int
+	term termQuery
+	pos  int
+}
+
+// lexQuery splits a query into tokens
+func lexQuery(expr string) ([]queryToken, error) {
+	toks := []queryToken{}
+	r := []rune(expr)
+	for i := 0; i < len(r); {
+		switch c := r[i]; {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			toks = append(toks, queryToken{kind: tokOpen, pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, queryToken{kind: tokClose, pos: i})
+			i++
+		case c == '-' && i+1 < len(r) && !unicode.IsSpace(r[i+1]):
+			toks = append(toks, queryToken{kind: tokNot, pos: i})
+			i++
+		default:
+			start := i
+			field, word, quoted := "", []rune{}, false
+			for i < len(r) && !unicode.IsSpace(r[i]) && r[i] != '(' && r[i] != ')' {
+				switch {
+				case r[i] == '"':
+					end := i + 1
+					for end < len(r) && r[end] != '"' {
+						end++
+					}
+					if end == len(r) {
+						return nil, fmt.Errorf("unclosed quote at %d", i+1)
+					}
+					word = append(word, r[i+1:end]...)
+					quoted = true
+					i = end + 1
+				case r[i] == ':' && field == "" && !quoted && len(word) > 0:
+					field, word = string(word), []rune{}
+					i++
+				default:
+					word = append(word, r[i])
+					i++
+				}
+			}
+
+			t := queryToken{kind: tokTerm, term: termQuery{field, strings.ToLower(string(word))}, pos: start}
+			if field == "" && !quoted {
+				switch string(word) {
+				case "AND":
+					t.kind = tokAnd
+				case "OR":
+					t.kind = tokOr
+				case "NOT":
+					t.kind = tokNot
+				}
+			}
+			if t.kind == tokTerm && t.term.value == "" {
+				return nil, fmt.Errorf("empty value for %s: at %d", field, start+1)
+			}
+			toks = append(toks, t)
+		}
+	}
+	return append(toks, queryToken{kind: tokEnd, pos: len(r)}), nil
+}
+
+// queryParser is a recursive descent parser of
+//
+//	or   = and {"OR" and}
+//	and  = not {["AND"] not}
+//	not  = ("NOT" | "-") not | "(" or ")" | term
+type queryParser struct {
+	toks []queryToken
+	i    int
+}
+
+func (p *queryParser) peek() queryToken { return p.toks[p.i] }
+func (p *queryParser) next() queryToken { t := p.toks[p.i]; p.i++; return t }
+
+func (p *queryParser) or() (Query, error) {
+	qs := orQuery{}
+	for {
+		q, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		qs = append(qs, q)
+		if p.peek().kind != tokOr {
+			break
+		}
+		p.next()
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return qs, nil
+}
+
+func (p *queryParser) and() (Query, error) {
+	qs := andQuery{}
+	for {
+		q, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		qs = append(qs, q)
+
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokTerm, tokNot, tokOpen:
+		default:
+			return And(qs...), nil
+		}
+	}
+}
+
+func (p *queryParser) not() (Query, error) {
+	switch t := p.next(); t.kind {
+	case tokNot:
+		q, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not(q), nil
+	case tokOpen:
+		q, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokClose {
+			return nil, fmt.Errorf("missing ) for ( at %d", t.pos+1)
+		}
+		return q, nil
+	case tokTerm:
+		return t.term, nil
+	case tokEnd:
+		return nil, fmt.Errorf("query ends early")
+	default:
+		return nil, fmt.Errorf("unexpected %s at %d", tokenName(t.kind), t.pos+1)
+	}
+}
+
+func tokenName(kind int) string {
+	return map[int]string{tokAnd: "AND", tokOr: "OR", tokClose: ")"}[kind]
+}
+
+// ParseQuery parses a query expression, an empty one selects everything.
+func ParseQuery(expr string) (Query, error) {
+	toks, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 1 {
+		return andQuery{}, nil
+	}
+
+	p := &queryParser{toks: toks}
+	q, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEnd {
+		return nil, fmt.Errorf("unexpected %s at %d", tokenName(t.kind), t.pos+1)
+	}
+	return q, nil
+}
@@ -0,0 +1,59 @@
+package pipetdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestQuery(t *testing.T) {
+	sns := []*Snippet{
+		{Meta: metadata{UID: "a.txt", Title: "Deploy app", Tags: []string{"k8s"}}, Data: "helm upgrade app\n"},
+		{Meta: metadata{UID: "b.txt", Title: "List pods", Tags: []string{"k8s", "old"}}, Data: "kubectl get pods\n"},
+		{Meta: metadata{UID: "c.txt", Title: "Pods by node", Tags: []string{"k8s-extra"}}, Data: "kubectl get pods -o wide\n"},
+		{Meta: metadata{UID: "d.txt", Title: "Disk usage", Tags: []string{"linux"},
+			Extra: yaml.MapSlice{{Key: "Owner", Value: "ops"}}}, Data: "df -h\n"},
+	}
+
+	cases := map[string][]string{
+		"":                        {"a.txt", "b.txt", "c.txt", "d.txt"},
+		"tag:k8s":                 {"a.txt", "b.txt"},
+		"tag:K8S*":                {"a.txt", "b.txt", "c.txt"},
+		"title:pods":              {"b.txt", "c.txt"},
+		`title:"disk usage"`:      {"d.txt"},
+		"body:kubectl -tag:old":   {"c.txt"},
+		"uid:a":                   {"a.txt"},
+		"Owner:OPS":               {"d.txt"},
+		"owner:ops":               {},
+		"pods":                    {"b.txt", "c.txt"},
+		"linux OR helm":           {"a.txt", "d.txt"},
+		"tag:k8s AND pods":        {"b.txt"},
+		"NOT tag:k8s*":            {"d.txt"},
+		"-(tag:k8s OR tag:linux)": {"c.txt"},
+		"foo-bar OR df":           {"d.txt"},
+		"tag:k8s AND (title:deploy OR body:kubectl) -tag:old": {"a.txt"},
+	}
+	for expr, want := range cases {
+		q, err := ParseQuery(expr)
+		assert.Nil(t, err, "%q should parse", expr)
+		got := []string{}
+		for _, s := range FilterSnippets(sns, q) {
+			got = append(got, s.Meta.UID)
+		}
+		assert.Equal(t, want, got, "%q", expr)
+	}
+
+	for _, expr := range []string{"(tag:k8s", "tag:k8s)", "tag:", `title:"open`, "a OR", "AND a", "NOT"} {
+		_, err := ParseQuery(expr)
+		assert.NotNil(t, err, "%q should not parse", expr)
+	}
+
+	q, _ := ParseQuery("tag:k8s title:x")
+	assert.False(t, q.NeedsBody(), "fields other than body don't need it")
+	q, _ = ParseQuery("tag:k8s OR -kubectl")
+	assert.True(t, q.NeedsBody(), "bare words need the body")
+
+	q = And(Term("tag", "K8S"), Not(Term("tag", "old")))
+	assert.Len(t, FilterSnippets(sns, q), 1, "queries can be built")
+}
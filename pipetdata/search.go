@@ -0,0 +1,299 @@
+package pipetdata
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// field weights used for ranking, a hit in the title counts more than one in
+// the body.
+var fieldWeights = [...]float64{titleField: 3, tagsField: 2, bodyField: 1}
+
+type field int
+
+const (
+	titleField field = iota
+	tagsField
+	bodyField
+	numFields
+)
+
+type token struct {
+	term       string
+	start, end int // byte offsets in the source text
+}
+
+// tokenize splits text into lower cased words of letters, digits and _.
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+		if word && start == -1 {
+			start = i
+		} else if !word && start != -1 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+type posting struct {
+	doc       int
+	positions [numFields][]int
+}
+
+// SearchIndex is an inverted index over the titles, tags and bodies of a set
+// of snippets.
+type SearchIndex struct {
+	docs     []*Snippet
+	postings map[string][]*posting
+	terms    []string // sorted, for prefix lookups
+}
+
+// NewSearchIndex indexes sns, the snippets need their Data filled in.
+func NewSearchIndex(sns []*Snippet) *SearchIndex {
+	idx := &SearchIndex{docs: sns, postings: map[string][]*posting{}}
+
+	for doc, s := range sns {
+		fields := [numFields]string{
+			titleField: s.Meta.Title,
+			tagsField:  strings.Join(s.Meta.Tags, " "),
+			bodyField:  s.Data,
+		}
+
+		byTerm := map[string]*posting{}
+		for f, text := range fields {
+			for pos, t := range tokenize(text) {
+				p, ok := byTerm[t.term]
+				if !ok {
+					p = &posting{doc: doc}
+					byTerm[t.term] = p
+					idx.postings[t.term] = append(idx.postings[t.term], p)
+				}
+				p.positions[f] = append(p.positions[f], pos)
+			}
+		}
+	}
+
+	for t := range idx.postings {
+		idx.terms = append(idx.terms, t)
+	}
+	sort.Strings(idx.terms)
+	return idx
+}
+
+// clause is one part of a query: a word, a word prefix (foo*) or a "quoted
+// phrase".
+type clause struct {
+	words  []string
+	prefix bool
+}
+
+// parseQuery splits a query into clauses, all of which have to match.
+func parseQuery(q string) []clause {
+	clauses := []clause{}
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		var part string
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			if end == -1 {
+				part, q = q[1:], ""
+			} else {
+				part, q = q[1:end+1], q[end+2:]
+			}
+			c := clause{}
+			for _, t := range tokenize(part) {
+				c.words = append(c.words, t.term)
+			}
+			if len(c.words) > 0 {
+				clauses = append(clauses, c)
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(q, unicode.IsSpace)
+		if end == -1 {
+			end = len(q)
+		}
+		part, q = q[:end], q[end:]
+
+		prefix := strings.HasSuffix(part, "*")
+		for _, t := range tokenize(strings.TrimSuffix(part, "*")) {
+			clauses = append(clauses, clause{words: []string{t.term}})
+		}
+		if prefix && len(clauses) > 0 {
+			clauses[len(clauses)-1].prefix = true
+		}
+	}
+	return clauses
+}
+
+// expand returns the indexed terms a word matches.
+func (idx *SearchIndex) expand(word string, prefix bool) []string {
+	if !prefix {
+		if _, ok := idx.postings[word]; ok {
+			return []string{word}
+		}
+		return nil
+	}
+
+	terms := []string{}
+	for i := sort.SearchStrings(idx.terms, word); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], word); i++ {
+		terms = append(terms, idx.terms[i])
+	}
+	return terms
+}
+
+// match scores every document matching the clause.
+func (idx *SearchIndex) match(c clause) map[int]float64 {
+	scores := map[int]float64{}
+	n := float64(len(idx.docs))
+
+	if len(c.words) == 1 {
+		for _, term := range idx.expand(c.words[0], c.prefix) {
+			ps := idx.postings[term]
+			idf := math.Log(1 + n/float64(len(ps)))
+			for _, p := range ps {
+				for f := range p.positions {
+					scores[p.doc] += fieldWeights[f] * float64(len(p.positions[f])) * idf
+				}
+			}
+		}
+		return scores
+	}
+
+	// phrase, the words have to follow each other in the same field
+	first := idx.postings[c.words[0]]
+	idf := math.Log(1 + n/float64(len(first)+1))
+	for _, p := range first {
+		for f := field(0); f < numFields; f++ {
+			hits := 0
+			for _, pos := range p.positions[f] {
+				if idx.phraseAt(p.doc, f, pos, c.words[1:]) {
+					hits++
+				}
+			}
+			scores[p.doc] += fieldWeights[f] * float64(hits) * idf * float64(len(c.words))
+		}
+		if scores[p.doc] == 0 {
+			delete(scores, p.doc)
+		}
+	}
+	return scores
+}
+
+// phraseAt checks that the rest of a phrase follows pos in the field.
+func (idx *SearchIndex) phraseAt(doc int, f field, pos int, rest []string) bool {
+	for i, w := range rest {
+		// postings are in document order
+		ps := idx.postings[w]
+		j := sort.Search(len(ps), func(j int) bool { return ps[j].doc >= doc })
+		if j == len(ps) || ps[j].doc != doc {
+			return false
+		}
+
+		found := false
+		for _, q := range ps[j].positions[f] {
+			if q == pos+i+1 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchLine is a line of a snippet body matching a query, Spans are the byte
+// ranges of matching words.
+type MatchLine struct {
+	Number int // starting at 1
+	Text   string
+	Spans  [][2]int
+}
+
+// SearchResult is a snippet matching a query
+type SearchResult struct {
+	Snippet *Snippet
+	Score   float64
+	Lines   []MatchLine
+}
+
+// Search returns the snippets matching every clause of query, best first.
+func (idx *SearchIndex) Search(query string) []SearchResult {
+	clauses := parseQuery(query)
+	if len(clauses) == 0 {
+		return []SearchResult{}
+	}
+
+	var total map[int]float64
+	for _, c := range clauses {
+		scores := idx.match(c)
+		if total == nil {
+			total = scores
+			continue
+		}
+		for doc := range total {
+			if s, ok := scores[doc]; ok {
+				total[doc] += s
+			} else {
+				delete(total, doc)
+			}
+		}
+	}
+
+	results := []SearchResult{}
+	for doc, score := range total {
+		s := idx.docs[doc]
+		results = append(results, SearchResult{s, score, matchLines(s.Data, clauses)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Snippet.Meta.Title < results[j].Snippet.Meta.Title
+	})
+	return results
+}
+
+// matchLines finds the body lines containing any word of the query.
+func matchLines(body string, clauses []clause) []MatchLine {
+	lines := []MatchLine{}
+	for n, line := range strings.Split(body, "\n") {
+		spans := [][2]int{}
+		for _, t := range tokenize(line) {
+			if clauseWord(t.term, clauses) {
+				spans = append(spans, [2]int{t.start, t.end})
+			}
+		}
+		if len(spans) > 0 {
+			lines = append(lines, MatchLine{n + 1, line, spans})
+		}
+	}
+	return lines
+}
+
+func clauseWord(term string, clauses []clause) bool {
+	for _, c := range clauses {
+		for _, w := range c.words {
+			if term == w || (c.prefix && len(c.words) == 1 && strings.HasPrefix(term, w)) {
+				return true
+			}
+		}
+	}
+	return false
+}
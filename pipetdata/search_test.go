@@ -0,0 +1,54 @@
+package pipetdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var searchCorpus = []*Snippet{
+	{Meta: metadata{UID: "a.txt", Title: "Kernel version", Tags: []string{"linux"}}, Data: "uname -a\n"},
+	{Meta: metadata{UID: "b.txt", Title: "Restart deployment", Tags: []string{"k8s"}},
+		Data: "kubectl rollout restart deployment/web\nkubectl get pods\n"},
+	{Meta: metadata{UID: "c.txt", Title: "List pods", Tags: []string{"k8s"}}, Data: "kubectl get pods -A\n"},
+	{Meta: metadata{UID: "d.txt", Title: "Disk usage", Tags: []string{"linux"}}, Data: "du -sh * | sort -h\n# pods get restarted\n"},
+}
+
+func uids(rs []SearchResult) []string {
+	out := []string{}
+	for _, r := range rs {
+		out = append(out, r.Snippet.Meta.UID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	idx := NewSearchIndex(searchCorpus)
+
+	assert.Equal(t, []string{"a.txt"}, uids(idx.Search("uname")), "body match")
+	assert.Equal(t, []string{"d.txt", "a.txt"}, uids(idx.Search("LINUX")), "tags match case insensitively")
+	assert.Empty(t, idx.Search("nothing"), "no match")
+	assert.Empty(t, idx.Search(""), "empty query")
+
+	// title hits rank above body hits
+	assert.Equal(t, []string{"c.txt", "d.txt", "b.txt"}, uids(idx.Search("pods")), "ranking")
+
+	// every clause has to match
+	assert.Equal(t, []string{"b.txt"}, uids(idx.Search("kubectl restart")), "and")
+
+	// equal scores are ordered by title
+	assert.Equal(t, []string{"c.txt", "b.txt"}, uids(idx.Search(`"get pods"`)), "phrase")
+	assert.Empty(t, idx.Search(`"pods get restarted deployment"`), "phrase has to be contiguous")
+
+	assert.Equal(t, []string{"b.txt", "d.txt"}, uids(idx.Search("restart*")), "prefix")
+}
+
+func TestSearchLines(t *testing.T) {
+	idx := NewSearchIndex(searchCorpus)
+
+	rs := idx.Search("rollout")
+	assert.Len(t, rs, 1, "one result")
+	assert.Equal(t, []MatchLine{
+		{Number: 1, Text: "kubectl rollout restart deployment/web", Spans: [][2]int{{8, 15}}},
+	}, rs[0].Lines, "matching line with word offsets")
+}
@@ -0,0 +1,374 @@
+package pipetdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// A site is a directory of static files, browsable without a server:
+//
+//	index.html          snippets grouped by tag, with a search box
+//	snippets/<uid>.html a page per snippet
+//	style.css, pipet.js
+//	search-index.js     titles, tags and bodies for the search box
+//
+// Nothing is loaded from the network. The search index is a script rather
+// than json since browsers don't fetch files from file:// urls.
+const siteDir = "snippets"
+
+var (
+	siteStyle     = styles.Get("github")
+	siteFormatter = html.New(html.WithClasses())
+)
+
+// siteSnippet is a snippet as shown on the site
+type siteSnippet struct {
+	Title   string
+	Tags    []siteTag
+	Page    string
+	Created string
+	Updated string
+	Extra   string
+	Body    template.HTML
+}
+
+type siteTag struct {
+	Name     string
+	Anchor   string
+	Snippets []*siteSnippet
+}
+
+// siteEntry is a snippet in the search index
+type siteEntry struct {
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+	Page  string   `json:"page"`
+	Body  string   `json:"body"`
+}
+
+// WriteSite writes sns as a static site with the given title into dir,
+// replacing the pages of an earlier export there. Bodies are highlighted
+// as the language metadata, a tag naming a language or whatever they look
+// like.
+func WriteSite(dir, title string, sns []*Snippet) error {
+	pages := filepath.Join(dir, siteDir)
+	if err := os.MkdirAll(pages, 0755); err != nil {
+		return err
+	}
+	old, err := filepath.Glob(filepath.Join(pages, "*.html"))
+	if err != nil {
+		return err
+	}
+	for _, fn := range old {
+		if err := os.Remove(fn); err != nil {
+			return err
+		}
+	}
+
+	sns = append([]*Snippet{}, sns...)
+	SortSnippets(sns, "title")
+
+	tags := map[string]*siteTag{}
+	index := []siteEntry{}
+	for _, s := range sns {
+		page, err := newSiteSnippet(s)
+		if err != nil {
+			return errors.Wrapf(err, "rendering %s failed", s.Meta.UID)
+		}
+
+		names := s.Meta.Tags
+		if len(names) == 0 {
+			names = []string{"untagged"}
+		}
+		for _, name := range names {
+			t, ok := tags[name]
+			if !ok {
+				t = &siteTag{Name: name, Anchor: tagAnchor(name)}
+				tags[name] = t
+			}
+			t.Snippets = append(t.Snippets, page)
+			page.Tags = append(page.Tags, siteTag{Name: name, Anchor: t.Anchor})
+		}
+
+		if err := writeTemplate(filepath.Join(dir, page.Page), snippetPage, struct {
+			Site string
+			*siteSnippet
+		}{title, page}); err != nil {
+			return err
+		}
+		index = append(index, siteEntry{Title: s.Meta.Title, Tags: s.Meta.Tags, Page: page.Page, Body: s.Data})
+	}
+
+	byName := []*siteTag{}
+	for _, t := range tags {
+		byName = append(byName, t)
+	}
+	sort.Slice(byName, func(i, j int) bool {
+		return strings.ToLower(byName[i].Name) < strings.ToLower(byName[j].Name)
+	})
+	if err := writeTemplate(filepath.Join(dir, "index.html"), indexPage, struct {
+		Site  string
+		Count int
+		Tags  []*siteTag
+	}{title, len(sns), byName}); err != nil {
+		return err
+	}
+
+	js, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	js = append(append([]byte("var pipetIndex = "), js...), ";\n"...)
+	if err := ioutil.WriteFile(filepath.Join(dir, "search-index.js"), js, 0644); err != nil {
+		return err
+	}
+
+	var css bytes.Buffer
+	css.WriteString(siteCSS)
+	if err := siteFormatter.WriteCSS(&css, siteStyle); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "style.css"), css.Bytes(), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "pipet.js"), []byte(siteJS), 0644)
+}
+
+func newSiteSnippet(s *Snippet) (*siteSnippet, error) {
+	page := &siteSnippet{
+		Title: s.Meta.Title,
+		Page:  siteDir + "/" + strings.TrimSuffix(s.Meta.UID, ".txt") + ".html",
+	}
+	if s.Meta.Created != nil {
+		page.Created = s.Meta.Created.Format("2006-01-02 15:04")
+	}
+	if s.Meta.Updated != nil {
+		page.Updated = s.Meta.Updated.Format("2006-01-02 15:04")
+	}
+	if len(s.Meta.Extra) > 0 {
+		extra, err := yaml.Marshal(s.Meta.Extra)
+		if err != nil {
+			return nil, err
+		}
+		page.Extra = string(extra)
+	}
+
+	it, err := chroma.Coalesce(snippetLexer(s)).Tokenise(nil, s.Data)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := siteFormatter.Format(&body, siteStyle, it); err != nil {
+		return nil, err
+	}
+	page.Body = template.HTML(body.String())
+	return page, nil
+}
+
+// snippetLexer picks a highlighter for the snippet body
+func snippetLexer(s *Snippet) chroma.Lexer {
+	if lang, ok := s.Field("language"); ok {
+		if l := lexers.Get(fmt.Sprint(lang)); l != nil {
+			return l
+		}
+	}
+	for _, t := range s.Meta.Tags {
+		if l := lexers.Get(t); l != nil {
+			return l
+		}
+	}
+	if l := lexers.Analyse(s.Data); l != nil {
+		return l
+	}
+	return lexers.Fallback
+}
+
+// tagAnchor makes a tag usable as an html id
+func tagAnchor(tag string) string {
+	return "tag-" + strings.Map(func(r rune) rune {
+		if r < 128 && (isAlnum(byte(r)) || r == '-' || r == '_') {
+			return r
+		}
+		return '_'
+	}, tag)
+}
+
+func writeTemplate(fn string, t *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return errors.Wrapf(err, "rendering %s failed", fn)
+	}
+	return ioutil.WriteFile(fn, buf.Bytes(), 0644)
+}
+
+var indexPage = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Site}}</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<header>
+<h1><a href="index.html">{{.Site}}</a> <small>{{.Count}} snippets</small></h1>
+<input id="search" type="search" placeholder="Search titles, tags and snippets" autofocus>
+</header>
+<main>
+<ul id="results" hidden></ul>
+<div id="tags">
+<nav class="taglist">{{range .Tags}}<a href="#{{.Anchor}}">{{.Name}}</a> {{end}}</nav>
+{{range .Tags}}<section id="{{.Anchor}}">
+<h2>{{.Name}} <small>{{len .Snippets}}</small></h2>
+<ul>{{range .Snippets}}
+<li><a href="{{.Page}}">{{.Title}}</a></li>{{end}}
+</ul>
+</section>
+{{end}}</div>
+</main>
+<script src="search-index.js"></script>
+<script src="pipet.js"></script>
+</body>
+</html>
+`))
+
+var snippetPage = template.Must(template.New("snippet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - {{.Site}}</title>
+<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<header>
+<h1><a href="../index.html">{{.Site}}</a></h1>
+</header>
+<main>
+<h2>{{.Title}}</h2>
+<p class="taglist">{{range .Tags}}<a href="../index.html#{{.Anchor}}">{{.Name}}</a> {{end}}</p>
+<p class="dates">{{if .Created}}created {{.Created}}{{end}}{{if .Updated}} &middot; updated {{.Updated}}{{end}}</p>
+{{if .Extra}}<pre class="extra">{{.Extra}}</pre>
+{{end}}<div class="body">
+<button class="copy">Copy</button>
+{{.Body}}
+</div>
+</main>
+<script src="../pipet.js"></script>
+</body>
+</html>
+`))
+
+const siteCSS = `body { font-family: sans-serif; max-width: 60em; margin: 0 auto; padding: 0 1em; color: #222; }
+a { color: #0366d6; text-decoration: none; }
+a:hover { text-decoration: underline; }
+h1 small, h2 small { color: #888; font-weight: normal; font-size: 60%; }
+#search { width: 100%; font-size: 1.1em; padding: .4em; box-sizing: border-box; }
+#results li span, .taglist a { background: #eef; border-radius: 3px; padding: 0 .4em; margin-right: .3em; font-size: 90%; }
+.dates { color: #888; }
+pre { padding: .8em; overflow-x: auto; border: 1px solid #ddd; border-radius: 3px; }
+.extra { background: #fafafa; }
+.body { position: relative; }
+.copy { position: absolute; right: .5em; top: .5em; }
+`
+
+const siteJS = `(function () {
+  function copy(text, button) {
+    var done = function () {
+      button.textContent = 'Copied';
+      setTimeout(function () { button.textContent = 'Copy'; }, 1500);
+    };
+    if (navigator.clipboard) {
+      navigator.clipboard.writeText(text).then(done);
+      return;
+    }
+    var area = document.createElement('textarea');
+    area.value = text;
+    document.body.appendChild(area);
+    area.select();
+    document.execCommand('copy');
+    document.body.removeChild(area);
+    done();
+  }
+
+  var buttons = document.querySelectorAll('button.copy');
+  for (var i = 0; i < buttons.length; i++) {
+    buttons[i].addEventListener('click', function (e) {
+      var pre = e.target.parentNode.querySelector('pre');
+      copy(pre.textContent, e.target);
+    });
+  }
+
+  var input = document.getElementById('search');
+  if (!input || !window.pipetIndex) {
+    return;
+  }
+  var results = document.getElementById('results');
+  var tags = document.getElementById('tags');
+
+  // every word has to match, hits in titles count more than tags and bodies
+  function score(s, words) {
+    var title = s.title.toLowerCase(), tags = (s.tags || []).join(' ').toLowerCase(), body = s.body.toLowerCase();
+    var total = 0;
+    for (var i = 0; i < words.length; i++) {
+      var w = (title.indexOf(words[i]) >= 0 ? 3 : 0) + (tags.indexOf(words[i]) >= 0 ? 2 : 0) +
+        (body.indexOf(words[i]) >= 0 ? 1 : 0);
+      if (!w) {
+        return 0;
+      }
+      total += w;
+    }
+    return total;
+  }
+
+  input.addEventListener('input', function () {
+    var words = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.hidden = !words.length;
+    tags.hidden = !!words.length;
+    if (!words.length) {
+      return;
+    }
+
+    var hits = [];
+    for (var i = 0; i < pipetIndex.length; i++) {
+      var n = score(pipetIndex[i], words);
+      if (n) {
+        hits.push({ s: pipetIndex[i], n: n });
+      }
+    }
+    hits.sort(function (a, b) { return b.n - a.n || a.s.title.localeCompare(b.s.title); });
+
+    results.innerHTML = '';
+    for (var j = 0; j < hits.length; j++) {
+      var li = document.createElement('li'), a = document.createElement('a');
+      a.href = hits[j].s.page;
+      a.textContent = hits[j].s.title;
+      li.appendChild(a);
+      (hits[j].s.tags || []).forEach(function (t) {
+        var span = document.createElement('span');
+        span.textContent = t;
+        li.appendChild(document.createTextNode(' '));
+        li.appendChild(span);
+      });
+      results.appendChild(li);
+    }
+    if (!hits.length) {
+      results.innerHTML = '<li>no matches</li>';
+    }
+  });
+})();
+`
@@ -0,0 +1,51 @@
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWriteSite(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "creating temp dir failed")
+	defer os.RemoveAll(tmpdir)
+
+	sns := []*Snippet{
+		{Meta: metadata{UID: "a.txt", Title: "Print <b>", Tags: []string{"go", "fmt"}}, Data: "fmt.Println(\"hi\")\n"},
+		{Meta: metadata{UID: "b.txt", Title: "Untagged", Extra: yaml.MapSlice{{Key: "owner", Value: "ops"}}}, Data: "ls -l\n"},
+	}
+
+	stale := filepath.Join(tmpdir, siteDir, "gone.html")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(stale), 0755))
+	assert.Nil(t, ioutil.WriteFile(stale, nil, 0644))
+
+	assert.Nil(t, WriteSite(tmpdir, "Team snippets", sns), "should write")
+
+	for _, fn := range []string{"index.html", "style.css", "pipet.js", "search-index.js", "snippets/a.html", "snippets/b.html"} {
+		_, err := os.Stat(filepath.Join(tmpdir, fn))
+		assert.Nil(t, err, fn+" written")
+	}
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err), "old pages removed")
+
+	index, _ := ioutil.ReadFile(filepath.Join(tmpdir, "index.html"))
+	assert.Contains(t, string(index), `<section id="tag-go">`, "grouped by tag")
+	assert.Contains(t, string(index), `<section id="tag-untagged">`, "untagged group")
+	assert.Contains(t, string(index), `<a href="snippets/a.html">Print &lt;b&gt;</a>`, "titles are escaped")
+
+	page, _ := ioutil.ReadFile(filepath.Join(tmpdir, "snippets/a.html"))
+	assert.Contains(t, string(page), `<pre class="chroma">`, "highlighted body")
+	assert.Contains(t, string(page), `<span class="s">&#34;hi&#34;</span>`, "highlighted as go")
+	assert.Contains(t, string(page), `<a href="../index.html#tag-fmt">fmt</a>`, "tags link to the index")
+
+	page, _ = ioutil.ReadFile(filepath.Join(tmpdir, "snippets/b.html"))
+	assert.Contains(t, string(page), "owner: ops", "extra metadata")
+
+	js, _ := ioutil.ReadFile(filepath.Join(tmpdir, "search-index.js"))
+	assert.Contains(t, string(js), `"title":"Print \u003cb\u003e"`, "search index, escaped for script")
+}
@@ -0,0 +1,458 @@
+//go:build cgo
+// +build cgo
+
+package pipetdata
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	// registers the sqlite3 driver with database/sql
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// schema version is kept in sqlite's user_version pragma, each entry in
+// sqliteMigrations upgrades the database by one version.
+var sqliteMigrations = []string{
+	`CREATE TABLE snippets (
+		uid   TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		body  TEXT NOT NULL
+	);
+	CREATE TABLE tags (
+		uid      TEXT NOT NULL REFERENCES snippets(uid) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		tag      TEXT NOT NULL,
+		PRIMARY KEY (uid, position)
+	);
+	CREATE INDEX tags_tag ON tags(tag);`,
+	`ALTER TABLE snippets ADD COLUMN archived TEXT;`,
+	`ALTER TABLE snippets ADD COLUMN created TEXT;
+	ALTER TABLE snippets ADD COLUMN updated TEXT;
+	ALTER TABLE snippets ADD COLUMN format_version INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE snippets ADD COLUMN extra TEXT;`,
+	`CREATE TABLE attachments (
+		uid  TEXT NOT NULL REFERENCES snippets(uid) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		mode INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		PRIMARY KEY (uid, name)
+	);`,
+}
+
+const snippetColumns = "uid, title, body, archived, created, updated, format_version, extra"
+
+// SQLStore keeps snippets in a single sqlite database file.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func init() {
+	Register("sqlite", func(path string) (Store, error) {
+		return NewSQLStore(path)
+	})
+}
+
+// NewSQLStore opens (creating if needed) the sqlite database at path.
+func NewSQLStore(path string) (*SQLStore, error) {
+	// sqlite reads the name as a uri, so ? and # in the path must be escaped
+	name := (&url.URL{Path: path}).EscapedPath()
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=1", name))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening database failed")
+	}
+
+	s := &SQLStore{db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return errors.Wrap(err, "reading schema version failed")
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(sqliteMigrations[version])
+		if err == nil {
+			_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1))
+		}
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "migrating schema to version %d failed", version+1)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Exist checks if a live snippet with the uid exists.
+func (s *SQLStore) Exist(id string) bool {
+	var n int
+	err := s.db.QueryRow("SELECT count(*) FROM snippets WHERE uid = ? AND archived IS NULL", id).Scan(&n)
+	return err == nil && n > 0
+}
+
+// New creates a new entry in snippets and returns its uid
+func (s *SQLStore) New(title string, tags ...string) (string, error) {
+	uid := newID()
+	if s.Exist(uid) {
+		return "", errors.New("duplicate snippet")
+	}
+
+	return uid, s.Write(&Snippet{Meta: newMetadata(uid, title, tags)})
+}
+
+// Write inserts or replaces the snippet and its tags.
+func (s *SQLStore) Write(sn *Snippet) error {
+	uid := sn.Meta.UID
+	if uid == "" {
+		return errors.New("snippet has no uid")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = writeSnippet(tx, sn)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "writing snippet failed")
+	}
+	return tx.Commit()
+}
+
+func writeSnippet(tx *sql.Tx, sn *Snippet) error {
+	uid := sn.Meta.UID
+	meta := sn.Meta
+	meta.upgrade(time.Now())
+
+	// extra fields are kept as the yaml they came from
+	var extra interface{}
+	if len(meta.Extra) > 0 {
+		buf, err := meta.marshalExtra()
+		if err != nil {
+			return err
+		}
+		extra = string(buf)
+	}
+
+	// a REPLACE would delete the row and cascade to the attachments
+	res, err := tx.Exec("UPDATE snippets SET title = ?, body = ?, archived = ?, created = ?, updated = ?, "+
+		"format_version = ?, extra = ? WHERE uid = ?",
+		meta.Title, sn.Data, timeValue(meta.Archived), timeValue(meta.Created),
+		timeValue(meta.Updated), meta.FormatVersion, extra, uid)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		_, err = tx.Exec("INSERT INTO snippets ("+snippetColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			uid, meta.Title, sn.Data, timeValue(meta.Archived), timeValue(meta.Created),
+			timeValue(meta.Updated), meta.FormatVersion, extra)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM tags WHERE uid = ?", uid)
+	if err != nil {
+		return err
+	}
+
+	for i, t := range sn.Meta.Tags {
+		_, err = tx.Exec("INSERT INTO tags (uid, position, tag) VALUES (?, ?, ?)", uid, i, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullTime scans a nullable timestamp column, stored as RFC3339 text.
+type nullTime struct {
+	t **time.Time
+}
+
+func (n nullTime) Scan(v interface{}) error {
+	var text string
+	switch v := v.(type) {
+	case nil:
+		*n.t = nil
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("unexpected timestamp type %T", v)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return err
+	}
+	*n.t = &t
+	return nil
+}
+
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSnippet(row scanner) (*Snippet, error) {
+	sn := &Snippet{}
+	var extra sql.NullString
+	err := row.Scan(&sn.Meta.UID, &sn.Meta.Title, &sn.Data, nullTime{&sn.Meta.Archived},
+		nullTime{&sn.Meta.Created}, nullTime{&sn.Meta.Updated}, &sn.Meta.FormatVersion, &extra)
+	if err == nil && extra.Valid {
+		sn.Meta.Extra, sn.Meta.raw, err = extraFields([]byte(extra.String))
+	}
+	return sn, err
+}
+
+// Read reads a snippet with its tags
+func (s *SQLStore) Read(id string) (*Snippet, error) {
+	sn, err := scanSnippet(s.db.QueryRow("SELECT "+snippetColumns+" FROM snippets WHERE uid = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("no such document")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading failed")
+	}
+
+	rows, err := s.db.Query("SELECT tag FROM tags WHERE uid = ? ORDER BY position", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading tags failed")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		sn.Meta.Tags = append(sn.Meta.Tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	files, err := s.db.Query("SELECT name FROM attachments WHERE uid = ? ORDER BY name", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading attachments failed")
+	}
+	defer files.Close()
+
+	for files.Next() {
+		var name string
+		if err := files.Scan(&name); err != nil {
+			return nil, err
+		}
+		sn.Meta.Files = append(sn.Meta.Files, name)
+	}
+	return sn, files.Err()
+}
+
+// List returns every live snippet ordered by uid
+func (s *SQLStore) List() (sns []*Snippet, err error) {
+	sns, err = s.query("archived IS NULL")
+	if err == nil && len(sns) == 0 {
+		err = ErrEmptyStore
+	}
+	return
+}
+
+// Archived returns every snippet in the trash ordered by uid
+func (s *SQLStore) Archived() ([]*Snippet, error) {
+	return s.query("archived IS NOT NULL")
+}
+
+// query loads the snippets matching the where clause along with their tags.
+func (s *SQLStore) query(where string) (sns []*Snippet, err error) {
+	sns = []*Snippet{}
+	byUID := map[string]*Snippet{}
+
+	rows, err := s.db.Query("SELECT " + snippetColumns + " FROM snippets WHERE " + where + " ORDER BY uid")
+	if err != nil {
+		return sns, errors.Wrap(err, "listing failed")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sn, e := scanSnippet(rows)
+		if e != nil {
+			return sns, e
+		}
+		sns = append(sns, sn)
+		byUID[sn.Meta.UID] = sn
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	tags, err := s.db.Query("SELECT uid, tag FROM tags WHERE uid IN (SELECT uid FROM snippets WHERE " +
+		where + ") ORDER BY uid, position")
+	if err != nil {
+		return sns, errors.Wrap(err, "listing tags failed")
+	}
+	defer tags.Close()
+
+	for tags.Next() {
+		var uid, t string
+		if err = tags.Scan(&uid, &t); err != nil {
+			return
+		}
+		if sn, ok := byUID[uid]; ok {
+			sn.Meta.Tags = append(sn.Meta.Tags, t)
+		}
+	}
+	if err = tags.Err(); err != nil {
+		return
+	}
+
+	files, err := s.db.Query("SELECT uid, name FROM attachments WHERE uid IN (SELECT uid FROM snippets WHERE " +
+		where + ") ORDER BY uid, name")
+	if err != nil {
+		return sns, errors.Wrap(err, "listing attachments failed")
+	}
+	defer files.Close()
+
+	for files.Next() {
+		var uid, name string
+		if err = files.Scan(&uid, &name); err != nil {
+			return
+		}
+		if sn, ok := byUID[uid]; ok {
+			sn.Meta.Files = append(sn.Meta.Files, name)
+		}
+	}
+	err = files.Err()
+	return
+}
+
+// Archive moves the snippet to the trash
+func (s *SQLStore) Archive(id string) error {
+	return s.setArchived(id, "archived IS NULL", now())
+}
+
+// Restore brings a snippet back from the trash
+func (s *SQLStore) Restore(id string) error {
+	return s.setArchived(id, "archived IS NOT NULL", nil)
+}
+
+func (s *SQLStore) setArchived(id, where string, t *time.Time) error {
+	res, err := s.db.Exec("UPDATE snippets SET archived = ? WHERE uid = ? AND "+where, timeValue(t), id)
+	if err != nil {
+		return errors.Wrap(err, "updating snippet failed")
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.New("no such document")
+	}
+	return nil
+}
+
+// Delete removes the snippet, tags and attachments go with it.
+func (s *SQLStore) Delete(id string) error {
+	res, err := s.db.Exec("DELETE FROM snippets WHERE uid = ?", id)
+	if err != nil {
+		return errors.Wrap(err, "delete failed")
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.New("no such document")
+	}
+	return nil
+}
+
+// Attach stores the file with the snippet, replacing one with the same name.
+func (s *SQLStore) Attach(id string, a *Attachment) error {
+	if !validAttachmentName(a.Name) {
+		return badAttachmentName(a.Name)
+	}
+	if !s.Exist(id) {
+		return errors.New("no such document")
+	}
+
+	mode := a.Mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	return s.changeFiles(id, "INSERT OR REPLACE INTO attachments (uid, name, mode, data) VALUES (?, ?, ?, ?)",
+		id, a.Name, int64(mode), a.Data)
+}
+
+// Detach removes the file from the snippet
+func (s *SQLStore) Detach(id, name string) error {
+	if !s.Exist(id) {
+		return errors.New("no such document")
+	}
+	return s.changeFiles(id, "DELETE FROM attachments WHERE uid = ? AND name = ?", id, name)
+}
+
+// changeFiles runs the change to the attachments and marks the snippet as
+// updated.
+func (s *SQLStore) changeFiles(id, query string, args ...interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err == nil {
+		if n, e := res.RowsAffected(); e == nil && n == 0 {
+			tx.Rollback()
+			return fmt.Errorf("no file %s in snippet", args[1])
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec("UPDATE snippets SET updated = ? WHERE uid = ?", timeValue(now()), id)
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "updating attachments failed")
+	}
+	return tx.Commit()
+}
+
+// Attachment reads a file of the snippet
+func (s *SQLStore) Attachment(id, name string) (*Attachment, error) {
+	a := &Attachment{Name: name}
+	var mode int64
+	err := s.db.QueryRow("SELECT mode, data FROM attachments WHERE uid = ? AND name = ?", id, name).Scan(&mode, &a.Data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no file %s in snippet", name)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading attachment failed")
+	}
+	a.Mode = os.FileMode(mode)
+	return a, nil
+}
@@ -0,0 +1,16 @@
+//go:build !cgo
+// +build !cgo
+
+package pipetdata
+
+import (
+	"github.com/pkg/errors"
+)
+
+// the sqlite driver is written in C, without cgo sqlite:// stores are refused
+// instead of failing the whole build.
+func init() {
+	Register("sqlite", func(path string) (Store, error) {
+		return nil, errors.New("sqlite stores need pipet built with cgo")
+	})
+}
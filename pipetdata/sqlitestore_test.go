@@ -0,0 +1,70 @@
+//go:build cgo
+// +build cgo
+
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStore(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	s, err := Open("sqlite://" + filepath.Join(tmpdir, "pipet.db"))
+	assert.Nil(t, err, "opening sqlite store")
+	testStore(t, s)
+
+	// the path is escaped before it is handed to sqlite as a uri
+	name := filepath.Join(tmpdir, "odd?name#.db")
+	_, err = NewSQLStore(name)
+	assert.Nil(t, err, "opening sqlite store")
+	_, err = os.Stat(name)
+	assert.Nil(t, err, "database is created under its name")
+}
+
+func TestSQLStoreAttach(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	s, err := NewSQLStore(filepath.Join(tmpdir, "pipet.db"))
+	assert.Nil(t, err, "opening sqlite store")
+	testAttacher(t, s)
+}
+
+func TestCopyStore(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	uid, err := ds.New("Kernel version", "linux", "kernel")
+	assert.Nil(t, err, "new snippet must be created")
+	assert.Nil(t, ds.Write(&Snippet{
+		Meta: metadata{UID: uid, Title: "Kernel version", Tags: []string{"linux", "kernel"}},
+		Data: "uname -a\n",
+	}), "write should succeed")
+
+	db, err := NewSQLStore(filepath.Join(tmpdir, "pipet.db"))
+	assert.Nil(t, err, "opening sqlite store")
+
+	n, err := CopyStore(db, ds)
+	assert.Nil(t, err, "copy to database")
+	assert.Equal(t, 1, n, "one snippet copied")
+
+	back := NewMemStore()
+	n, err = CopyStore(back, db)
+	assert.Nil(t, err, "copy back from database")
+	assert.Equal(t, 1, n, "one snippet copied")
+
+	orig, _ := ds.Read(uid)
+	copied, err := back.Read(uid)
+	assert.Nil(t, err, "copied snippet should keep its uid")
+	assert.Equal(t, orig, copied, "snippet should survive the round trip")
+}
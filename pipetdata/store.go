@@ -0,0 +1,220 @@
+package pipetdata
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// Store is implemented by every snippet storage backend. Snippets are
+// addressed by their UID, which is opaque to callers.
+//
+// Deleting a snippet through Archive only moves it to the trash, archived
+// snippets have Meta.Archived set and are left out of List.
+type Store interface {
+	// Exist checks if a live (not archived) snippet with the uid exists.
+	Exist(id string) bool
+	// New creates an empty snippet and returns its uid.
+	New(title string, tags ...string) (string, error)
+	// Read returns the snippet stored under id, archived or not.
+	Read(id string) (*Snippet, error)
+	// Write stores s under s.Meta.UID, replacing any existing snippet.
+	Write(s *Snippet) error
+	// List returns every live snippet in the store. Backends may leave Data
+	// empty to keep listing fast, use Read for the body.
+	List() ([]*Snippet, error)
+	// Delete removes the snippet permanently, archived or not.
+	Delete(id string) error
+
+	// Archive moves a live snippet to the trash, stamping Meta.Archived.
+	Archive(id string) error
+	// Restore brings an archived snippet back.
+	Restore(id string) error
+	// Archived returns every snippet in the trash.
+	Archived() ([]*Snippet, error)
+}
+
+// FileStore is implemented by backends keeping each snippet in its own file
+// on disk.
+type FileStore interface {
+	Store
+	// Fullpath returns the path to the file backing the snippet.
+	Fullpath(id string) string
+}
+
+// Batcher is implemented by stores that can record several changes as one,
+// like the git store making a single commit of them.
+type Batcher interface {
+	// Batch runs fn, the changes it makes are recorded together with msg.
+	Batch(msg string, fn func() error) error
+}
+
+// Batch runs fn as one change if the store is a Batcher, and just runs it
+// otherwise.
+func Batch(s Store, msg string, fn func() error) error {
+	if b, ok := s.(Batcher); ok {
+		return b.Batch(msg, fn)
+	}
+	return fn()
+}
+
+// Opener creates a Store from the path part of a store URI.
+type Opener func(path string) (Store, error)
+
+var backends = map[string]Opener{}
+
+// Register makes a backend available to Open under scheme.
+func Register(scheme string, open Opener) {
+	backends[scheme] = open
+}
+
+func init() {
+	Register("dir", func(path string) (Store, error) {
+		return NewDataStore(path)
+	})
+	Register("mem", func(path string) (Store, error) {
+		return NewMemStore(), nil
+	})
+}
+
+// Open opens the store described by uri, for e.g dir:///home/user/snippets or
+// mem://. A uri without a scheme is taken to be a directory, its path is used
+// as is while uri paths are unescaped.
+func Open(uri string) (Store, error) {
+	scheme, path := "dir", uri
+	if i := strings.Index(uri, "://"); i != -1 {
+		var err error
+		scheme = uri[:i]
+		if path, err = url.PathUnescape(uri[i+3:]); err != nil {
+			return nil, errors.Wrap(err, "bad store path")
+		}
+	}
+
+	open, ok := backends[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown store backend: %s", scheme)
+	}
+	return open(path)
+}
+
+// newID generates a fresh snippet uid. uids carry the .txt suffix on every
+// backend, so snippets can move between stores without being renamed.
+func newID() string {
+	return fmt.Sprintf("%s.txt", uuid.NewV4().String())
+}
+
+// ErrEmptyStore is returned by List when the store holds no live snippets.
+var ErrEmptyStore = errors.New("empty snippet store")
+
+// CopyStore writes every snippet in src, the trash included, into dst keeping
+// uids intact. Snippets already in dst with the same uid are replaced. It
+// returns the number of snippets copied, snippets that can't be read are
+// skipped and reported in a *ListError.
+func CopyStore(dst, src Store) (int, error) {
+	lerr := &ListError{Errs: map[string]error{}}
+	collect := func(err error) error {
+		if l, ok := err.(*ListError); ok {
+			for id, e := range l.Errs {
+				lerr.Errs[id] = e
+			}
+			return nil
+		}
+		if err == ErrEmptyStore {
+			return nil
+		}
+		return err
+	}
+
+	sns, err := src.List()
+	if err := collect(err); err != nil {
+		return 0, errors.Wrap(err, "listing source failed")
+	}
+
+	archived, err := src.Archived()
+	if err := collect(err); err != nil {
+		return 0, errors.Wrap(err, "listing source trash failed")
+	}
+	sns = append(sns, archived...)
+
+	n := 0
+	for _, s := range sns {
+		full, err := src.Read(s.Meta.UID)
+		if err != nil {
+			lerr.Errs[s.Meta.UID] = err
+			continue
+		}
+		if len(full.Meta.Files) > 0 {
+			if err := copyAttachments(dst, src, full); err != nil {
+				return n, errors.Wrapf(err, "copying files of %s failed", s.Meta.UID)
+			}
+		}
+		if err := dst.Write(full); err != nil {
+			return n, errors.Wrapf(err, "copying %s failed", s.Meta.UID)
+		}
+		n++
+	}
+	if len(lerr.Errs) > 0 {
+		return n, lerr
+	}
+	return n, nil
+}
+
+// copyAttachments copies the files of s, files can only be attached to live
+// snippets so archived ones are archived after. Writing s afterwards restores
+// its timestamps.
+func copyAttachments(dst, src Store, s *Snippet) error {
+	a, ok := dst.(Attacher)
+	if !ok {
+		return errors.New("destination can not keep files with snippets")
+	}
+	files, err := ReadAttachments(src, s.Meta.UID)
+	if err != nil {
+		return err
+	}
+
+	live := *s
+	live.Meta.Archived = nil
+	if err := dst.Write(&live); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := a.Attach(s.Meta.UID, f); err != nil {
+			return err
+		}
+	}
+	if s.Meta.Archived != nil {
+		return dst.Archive(s.Meta.UID)
+	}
+	return nil
+}
+
+// ReadAll returns every live snippet in the store with its body. Snippets
+// that can't be read are left out and reported in a *ListError.
+func ReadAll(s Store) ([]*Snippet, error) {
+	sns, err := s.List()
+	lerr, ok := err.(*ListError)
+	if err != nil && !ok {
+		return sns, err
+	}
+	if lerr == nil {
+		lerr = &ListError{Errs: map[string]error{}}
+	}
+
+	full := []*Snippet{}
+	for _, sn := range sns {
+		f, err := s.Read(sn.Meta.UID)
+		if err != nil {
+			lerr.Errs[sn.Meta.UID] = err
+			continue
+		}
+		full = append(full, f)
+	}
+
+	if len(lerr.Errs) > 0 {
+		return full, lerr
+	}
+	return full, nil
+}
@@ -0,0 +1,175 @@
+package pipetdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore exercises the Store contract, every backend should pass it.
+func testStore(t *testing.T, s Store) {
+	_, err := s.List()
+	assert.NotNil(t, err, "empty store should error on list")
+
+	uid, err := s.New("Kernel version", "linux", "kernel")
+	assert.Nil(t, err, "new snippet must be created")
+	assert.True(t, s.Exist(uid), "snippet should exist")
+
+	sn, err := s.Read(uid)
+	assert.Nil(t, err, "should be a valid snippet")
+	assert.Equal(t, uid, sn.Meta.UID, "uid should match")
+	assert.Equal(t, "Kernel version", sn.Meta.Title, "title should match")
+	assert.Equal(t, []string{"linux", "kernel"}, sn.Meta.Tags, "tags should match")
+	assert.NotNil(t, sn.Meta.Created, "creation time should be set")
+	assert.Equal(t, sn.Meta.Created, sn.Meta.Updated, "new snippet is unchanged")
+	assert.Equal(t, formatVersion, sn.Meta.FormatVersion, "current format")
+
+	sn.Data = "uname -a\n"
+	assert.Nil(t, sn.SetField("owner", "ops"), "extra field")
+	assert.Nil(t, s.Write(sn), "write should succeed")
+
+	sn, err = s.Read(uid)
+	assert.Nil(t, err, "should be a valid snippet")
+	assert.Equal(t, "uname -a\n", sn.Data, "data should match")
+	owner, _ := sn.Field("owner")
+	assert.Equal(t, "ops", owner, "extra field should be kept")
+
+	_, err = s.New("Uptime", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+
+	sns, err := s.List()
+	assert.Nil(t, err, "should not error")
+	assert.Len(t, sns, 2, "two snippets")
+	for _, l := range sns {
+		if l.Meta.UID == uid {
+			owner, _ := l.Field("owner")
+			assert.Equal(t, "ops", owner, "extra field should be listed")
+		}
+	}
+
+	assert.Nil(t, s.Archive(uid), "archive should succeed")
+	assert.False(t, s.Exist(uid), "archived snippet is not live")
+
+	sns, err = s.List()
+	assert.Nil(t, err, "should not error")
+	assert.Len(t, sns, 1, "archived snippet should not be listed")
+
+	archived, err := s.Archived()
+	assert.Nil(t, err, "listing trash should not error")
+	assert.Len(t, archived, 1, "one snippet in trash")
+	assert.NotNil(t, archived[0].Meta.Archived, "deletion time should be set")
+
+	sn, err = s.Read(uid)
+	assert.Nil(t, err, "archived snippet can be read")
+	assert.Equal(t, "uname -a\n", sn.Data, "archived data should match")
+
+	assert.Nil(t, s.Restore(uid), "restore should succeed")
+	assert.True(t, s.Exist(uid), "restored snippet should be live")
+	assert.NotNil(t, s.Restore(uid), "restoring a live snippet should fail")
+
+	sn, err = s.Read(uid)
+	assert.Nil(t, err, "restored snippet can be read")
+	assert.Nil(t, sn.Meta.Archived, "deletion time should be cleared")
+
+	assert.Nil(t, s.Archive(uid), "archive should succeed")
+	assert.Nil(t, s.Delete(uid), "should have deleted properly from trash")
+	assert.False(t, s.Exist(uid), "snippet should be gone")
+
+	archived, err = s.Archived()
+	assert.Nil(t, err, "listing trash should not error")
+	assert.Len(t, archived, 0, "trash should be empty")
+	assert.NotNil(t, s.Delete(uid), "second delete should fail")
+
+	_, err = s.Read(uid)
+	assert.NotNil(t, err, "no such snippet should exist.")
+}
+
+func TestMemStore(t *testing.T) {
+	s, err := Open("mem://")
+	assert.Nil(t, err, "opening memory store")
+	testStore(t, s)
+}
+
+func TestDirStore(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	s, err := Open("dir://" + tmpdir)
+	assert.Nil(t, err, "opening directory store")
+	_, ok := s.(FileStore)
+	assert.True(t, ok, "directory store should be a file store")
+	testStore(t, s)
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open("nosuch://foo")
+	assert.NotNil(t, err, "unknown backend should error")
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	s, err := Open(tmpdir)
+	assert.Nil(t, err, "plain path should open a directory store")
+	_, ok := s.(*DataStore)
+	assert.True(t, ok, "plain path should be a directory store")
+
+	// only uri paths are unescaped
+	s, err = Open(filepath.Join(tmpdir, "100%zz"))
+	assert.Nil(t, err, "plain path with a %")
+	assert.Equal(t, filepath.Join(tmpdir, "100%zz"), s.(*DataStore).documentDir, "plain path is kept")
+	s, err = Open("dir://" + filepath.Join(tmpdir, "a%20b"))
+	assert.Nil(t, err, "escaped uri path")
+	assert.Equal(t, filepath.Join(tmpdir, "a b"), s.(*DataStore).documentDir, "uri path is unescaped")
+}
+
+func TestDataStoreWriteBadID(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	_, err = ds.New("Kernel version", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+
+	for _, id := range []string{"", "..", ".", trashDir, "../escape.txt", "nosuffix", ".hidden.txt"} {
+		err = ds.Write(&Snippet{Meta: metadata{UID: id}})
+		assert.NotNil(t, err, "invalid uid %q should be rejected", id)
+		assert.False(t, ds.Exist(id), "invalid uid %q doesn't exist", id)
+		_, err = ds.Read(id)
+		assert.NotNil(t, err, "invalid uid %q can't be read", id)
+		assert.NotNil(t, ds.Delete(id), "invalid uid %q can't be deleted", id)
+	}
+
+	_, err = os.Stat(tmpdir)
+	assert.Nil(t, err, "store is still there")
+	sns, err := ds.List()
+	assert.Nil(t, err, "store can be listed")
+	assert.Len(t, sns, 1, "snippet is still there")
+}
+
+func TestCopyStorePartial(t *testing.T) {
+	n, err := CopyStore(NewMemStore(), NewMemStore())
+	assert.Nil(t, err, "copying an empty store should succeed")
+	assert.Equal(t, 0, n, "nothing to copy")
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+	ds, err := NewDataStore(tmpdir)
+	assert.Nil(t, err, "data store creation")
+
+	uid, err := ds.New("Kernel version", "linux")
+	assert.Nil(t, err, "new snippet must be created")
+	err = ioutil.WriteFile(filepath.Join(tmpdir, "badyaml.txt"), []byte("---\ntitle: [unclosed\n---\nuname -a\n"), 0644)
+	assert.Nil(t, err, "writing test file")
+
+	dst := NewMemStore()
+	n, err = CopyStore(dst, ds)
+	assert.IsType(t, &ListError{}, err, "broken files should be reported")
+	assert.Contains(t, err.(*ListError).Errs, "badyaml.txt", "broken uid is reported")
+	assert.Equal(t, 1, n, "readable snippet is copied")
+	assert.True(t, dst.Exist(uid), "readable snippet is in destination")
+}
@@ -0,0 +1,170 @@
+package pipetdata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// UsageLog records snippets being shown, edited, copied or run. It is kept
+// apart from the store, in a file of lines like
+//
+//	2018-06-01T10:00:00Z show 9f3c...txt
+//
+// The oldest uses are dropped once the log grows past maxUses.
+type UsageLog struct {
+	path string
+}
+
+// Use is a single use of a snippet
+type Use struct {
+	Time   time.Time
+	Action string
+	UID    string
+}
+
+// maxUses is how many uses the log keeps, it is compacted once the file is
+// about twice that size.
+const maxUses = 2000
+
+// NewUsageLog opens the log at path, the file is created on the first use.
+func NewUsageLog(path string) *UsageLog {
+	return &UsageLog{path: path}
+}
+
+// Record adds a use of the snippet to the log. The log is locked while it is
+// appended to and compacted, so concurrent pipets don't lose each other's uses.
+func (l *UsageLog) Record(action, uid string) error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening usage log failed")
+	}
+
+	err = l.record(f, action, uid)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return errors.Wrap(err, "writing usage log failed")
+	}
+	return nil
+}
+
+func (l *UsageLog) record(f *os.File, action, uid string) error {
+	unlock, err := lockFile(l.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := fmt.Fprintf(f, "%s %s %s\n", time.Now().UTC().Format(time.RFC3339), action, uid); err != nil {
+		return err
+	}
+	if fi, err := f.Stat(); err == nil && fi.Size() > 2*maxUses*80 {
+		return l.compact(f)
+	}
+	return nil
+}
+
+// compact keeps only the last maxUses lines. The file is rewritten in place
+// rather than replaced, a writer waiting on the lock would otherwise append
+// to the old file.
+func (l *UsageLog) compact(f *os.File) error {
+	uses, err := l.Uses()
+	if err != nil || len(uses) <= maxUses {
+		return err
+	}
+
+	var b strings.Builder
+	for _, u := range uses[len(uses)-maxUses:] {
+		fmt.Fprintf(&b, "%s %s %s\n", u.Time.Format(time.RFC3339), u.Action, u.UID)
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteString(b.String())
+	return err
+}
+
+// Uses returns every use in the log, oldest first. Lines that can't be read
+// are skipped, a missing log has no uses.
+func (l *UsageLog) Uses() ([]Use, error) {
+	uses := []Use{}
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return uses, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "opening usage log failed")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		uses = append(uses, Use{Time: t, Action: fields[1], UID: fields[2]})
+	}
+	return uses, scanner.Err()
+}
+
+// frecencyWeights score a use by its age, recent uses count for more
+var frecencyWeights = []struct {
+	age    time.Duration
+	weight float64
+}{
+	{4 * 24 * time.Hour, 100},
+	{14 * 24 * time.Hour, 70},
+	{31 * 24 * time.Hour, 50},
+	{90 * 24 * time.Hour, 30},
+}
+
+// Frecency scores snippets by how often and how recently they were used, the
+// sum of the weights of their uses.
+func Frecency(uses []Use, now time.Time) map[string]float64 {
+	scores := map[string]float64{}
+	for _, u := range uses {
+		weight := 10.0
+		for _, w := range frecencyWeights {
+			if now.Sub(u.Time) <= w.age {
+				weight = w.weight
+				break
+			}
+		}
+		scores[u.UID] += weight
+	}
+	return scores
+}
+
+// SortByFrecency orders sns by their scores, highest first. Snippets without
+// a score keep their order after the others.
+func SortByFrecency(sns []*Snippet, scores map[string]float64) {
+	sort.SliceStable(sns, func(i, j int) bool {
+		return scores[sns[i].Meta.UID] > scores[sns[j].Meta.UID]
+	})
+}
+
+// Recent returns the last use of the n most recently used snippets, newest
+// first. n <= 0 returns all of them.
+func Recent(uses []Use, n int) []Use {
+	recent := []Use{}
+	seen := map[string]bool{}
+	for i := len(uses) - 1; i >= 0 && (n <= 0 || len(recent) < n); i-- {
+		if !seen[uses[i].UID] {
+			seen[uses[i].UID] = true
+			recent = append(recent, uses[i])
+		}
+	}
+	return recent
+}
@@ -0,0 +1,122 @@
+package pipetdata
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageLog(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+	fn := filepath.Join(tmpdir, "usage")
+
+	l := NewUsageLog(fn)
+	uses, err := l.Uses()
+	assert.Nil(t, err, "missing log is fine")
+	assert.Empty(t, uses, "and has no uses")
+
+	assert.Nil(t, l.Record("show", "a.txt"), "record")
+	assert.Nil(t, l.Record("edit", "b.txt"), "record")
+	assert.Nil(t, l.Record("run", "a.txt"), "record")
+
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err, "opening log")
+	f.WriteString("garbage\nnot-a-time show c.txt\n")
+	f.Close()
+
+	uses, err = l.Uses()
+	assert.Nil(t, err, "reading log")
+	assert.Len(t, uses, 3, "bad lines are skipped")
+	assert.Equal(t, "edit", uses[1].Action, "action is kept")
+	assert.Equal(t, "a.txt", uses[2].UID, "oldest first")
+
+	recent := Recent(uses, 0)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, []string{recent[0].UID, recent[1].UID}, "newest first, once each")
+	assert.Equal(t, "run", recent[0].Action, "last use")
+	assert.Len(t, Recent(uses, 1), 1, "limited")
+
+	// compaction keeps the newest uses
+	lines := strings.Repeat(time.Now().UTC().Format(time.RFC3339)+" show old.txt\n", 10*maxUses)
+	assert.Nil(t, ioutil.WriteFile(fn, []byte(lines), 0644), "big log")
+	assert.Nil(t, l.Record("show", "new.txt"), "record")
+	uses, _ = l.Uses()
+	assert.Len(t, uses, maxUses, "log is compacted")
+	assert.Equal(t, "new.txt", uses[len(uses)-1].UID, "newest use is kept")
+}
+
+func TestUsageLogConcurrent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the usage log isn't locked on windows")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "testpipet")
+	assert.Nil(t, err, "tmp directory")
+	fn := filepath.Join(tmpdir, "usage")
+
+	// close to compaction, so it happens while others append
+	line := time.Now().UTC().Format(time.RFC3339) + " show old.txt\n"
+	lines := strings.Repeat(line, 2*maxUses*80/len(line)-50)
+	assert.Nil(t, ioutil.WriteFile(fn, []byte(lines), 0644), "big log")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := NewUsageLog(fn)
+			for j := 0; j < 20; j++ {
+				assert.Nil(t, l.Record("show", fmt.Sprintf("%d-%d.txt", i, j)), "record")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	uses, err := NewUsageLog(fn).Uses()
+	assert.Nil(t, err, "reading log")
+	found := 0
+	for _, u := range uses {
+		if u.UID != "old.txt" {
+			found++
+		}
+	}
+	assert.Equal(t, 200, found, "no use is lost")
+	assert.True(t, len(uses) < 2*maxUses, "log is compacted")
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	uses := []Use{
+		{now.Add(-100 * day), "show", "old.txt"},
+		{now.Add(-100 * day), "show", "old.txt"},
+		{now.Add(-100 * day), "show", "old.txt"},
+		{now.Add(-20 * day), "show", "often.txt"},
+		{now.Add(-10 * day), "show", "often.txt"},
+		{now.Add(-1 * day), "show", "often.txt"},
+		{now.Add(-1 * time.Hour), "copy", "new.txt"},
+	}
+
+	scores := Frecency(uses, now)
+	assert.Equal(t, map[string]float64{"old.txt": 30, "often.txt": 220, "new.txt": 100}, scores, "weighted by age")
+
+	sns := []*Snippet{}
+	for _, uid := range []string{"unused.txt", "old.txt", "new.txt", "often.txt", "never.txt"} {
+		sns = append(sns, &Snippet{Meta: metadata{UID: uid}})
+	}
+	SortByFrecency(sns, scores)
+	order := []string{}
+	for _, s := range sns {
+		order = append(order, s.Meta.UID)
+	}
+	assert.Equal(t, []string{"often.txt", "new.txt", "old.txt", "unused.txt", "never.txt"}, order,
+		"most used first, unused keep their order")
+}
@@ -0,0 +1,371 @@
+package pipetdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"gopkg.in/yaml.v2"
+)
+
+// A VS Code snippet file is a json object (comments and trailing commas
+// allowed) of
+//
+//	"name": {
+//		"prefix": "log",
+//		"body": ["console.log('${1:msg}');", "$0"],
+//		"description": "Log output to console",
+//		"scope": "javascript,typescript"
+//	}
+//
+// The name becomes the title and scope languages become tags. Snippet bodies
+// use tab stops like $1 or ${2:default}, pipet stores the text they insert with
+// the defaults filled in and keeps the original body in vscodeBody, so it is
+// written back unchanged by WriteVSCode as long as the text wasn't edited.
+const vscodeBody = "vscode_body"
+
+var vscodeNamespace = uuid.NewV5(uuid.NamespaceURL, "https://code.visualstudio.com/docs/editor/userdefinedsnippets")
+
+// vscodeLanguages maps tags to VS Code language ids
+var vscodeLanguages = map[string]string{
+	"sh": "shellscript", "bash": "shellscript", "zsh": "shellscript", "shell": "shellscript",
+	"shellscript": "shellscript", "go": "go", "golang": "go", "python": "python", "py": "python",
+	"javascript": "javascript", "js": "javascript", "typescript": "typescript", "ts": "typescript",
+	"ruby": "ruby", "rust": "rust", "c": "c", "cpp": "cpp", "java": "java", "sql": "sql",
+	"yaml": "yaml", "json": "json", "html": "html", "css": "css", "markdown": "markdown",
+	"dockerfile": "dockerfile", "makefile": "makefile", "powershell": "powershell", "lua": "lua",
+	"php": "php", "perl": "perl",
+}
+
+// ReadVSCode reads a VS Code snippets file
+func ReadVSCode(r io.Reader) ([]*Snippet, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(stripJSONC(buf)))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a VS Code snippets file")
+	}
+	entries, ok := v.(orderedMap)
+	if !ok {
+		return nil, errors.New("not a VS Code snippets file")
+	}
+
+	sns := []*Snippet{}
+	for _, entry := range entries {
+		name := fmt.Sprint(entry.Key)
+		fields, ok := entry.Value.(orderedMap)
+		if !ok {
+			return nil, fmt.Errorf("snippet %q is not an object", name)
+		}
+
+		var body string
+		extra := yaml.MapSlice{}
+		for _, f := range fields {
+			switch key := fmt.Sprint(f.Key); key {
+			case "body":
+				body, err = vscodeLines(f.Value)
+				if err != nil {
+					return nil, errors.Wrapf(err, "snippet %q", name)
+				}
+			default:
+				extra = append(extra, yaml.MapItem{Key: key, Value: yamlValue(f.Value)})
+			}
+		}
+
+		s := &Snippet{
+			Meta: metadata{
+				UID:   fmt.Sprintf("%s.txt", uuid.NewV5(vscodeNamespace, name+"\x00"+body)),
+				Title: name,
+			},
+			Data: vscodeText(body),
+		}
+		if len(extra) > 0 {
+			s.Meta.Extra = extra
+		}
+		if scope, ok := s.Field("scope"); ok {
+			for _, l := range strings.Split(fmt.Sprint(scope), ",") {
+				if l = strings.TrimSpace(l); l != "" {
+					s.Meta.Tags = append(s.Meta.Tags, l)
+				}
+			}
+		}
+		if vscodeEscape(s.Data) != body {
+			s.SetField(vscodeBody, body)
+		}
+		sns = append(sns, s)
+	}
+	return sns, nil
+}
+
+// vscodeLines joins a body given as a string or a list of lines
+func vscodeLines(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		lines := []string{}
+		for _, l := range v {
+			s, ok := l.(string)
+			if !ok {
+				return "", errors.New("body lines must be strings")
+			}
+			lines = append(lines, s)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", errors.New("body must be a string or a list of lines")
+}
+
+// WriteVSCode writes snippets as a VS Code snippets file. Titles are the
+// snippet names, the prefix and description extra fields are used if set and
+// the scope comes from the scope or language extra fields or language tags.
+func WriteVSCode(w io.Writer, sns []*Snippet) error {
+	out := orderedMap{}
+	names := map[string]int{}
+
+	for _, s := range sns {
+		name := s.Meta.Title
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, names[name])
+		}
+
+		text := strings.TrimSuffix(s.Data, "\n")
+		body := vscodeEscape(text)
+		if b, ok := s.Field(vscodeBody); ok {
+			if b, ok := b.(string); ok && strings.TrimSuffix(vscodeText(b), "\n") == text {
+				body = b
+			}
+		}
+
+		prefix, ok := s.Field("prefix")
+		if !ok {
+			prefix = strings.Join(strings.Fields(strings.ToLower(s.Meta.Title)), "-")
+		}
+		description, ok := s.Field("description")
+		if !ok {
+			description = s.Meta.Title
+		}
+
+		entry := orderedMap{{Key: "prefix", Value: jsonValue(prefix)}}
+		if scope := vscodeScope(s); scope != "" {
+			entry = append(entry, yaml.MapItem{Key: "scope", Value: scope})
+		}
+		entry = append(entry,
+			yaml.MapItem{Key: "body", Value: strings.Split(body, "\n")},
+			yaml.MapItem{Key: "description", Value: jsonValue(description)})
+		out = append(out, yaml.MapItem{Key: name, Value: entry})
+	}
+	return writeJSON(w, out)
+}
+
+func vscodeScope(s *Snippet) string {
+	if scope, ok := s.Field("scope"); ok {
+		return fmt.Sprint(scope)
+	}
+
+	if lang, ok := s.Field("language"); ok {
+		l := strings.ToLower(fmt.Sprint(lang))
+		if id, ok := vscodeLanguages[l]; ok {
+			return id
+		}
+		return l
+	}
+
+	seen := map[string]bool{}
+	for _, t := range s.Meta.Tags {
+		if id, ok := vscodeLanguages[strings.ToLower(t)]; ok {
+			seen[id] = true
+		}
+	}
+	ids := []string{}
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// vscodeEscape turns text into a snippet body inserting exactly that text
+func vscodeEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `$`, `\$`).Replace(text)
+}
+
+// vscodeText returns the text a snippet body inserts, tab stops and
+// placeholders are replaced by their defaults. Variables are kept as written,
+// in shell snippets they are usually meant for the shell.
+func vscodeText(body string) string {
+	p := &snippetParser{src: body}
+	return p.parse(false)
+}
+
+type snippetParser struct {
+	src string
+	i   int
+}
+
+func (p *snippetParser) peek() byte {
+	if p.i < len(p.src) {
+		return p.src[p.i]
+	}
+	return 0
+}
+
+// parse renders until the end, or the } closing a placeholder if nested
+func (p *snippetParser) parse(nested bool) string {
+	var out bytes.Buffer
+	for p.i < len(p.src) {
+		c := p.src[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.src) && strings.IndexByte(`$}\`, p.src[p.i+1]) >= 0:
+			out.WriteByte(p.src[p.i+1])
+			p.i += 2
+		case c == '}' && nested:
+			return out.String()
+		case c == '$':
+			out.WriteString(p.dollar())
+		default:
+			out.WriteByte(c)
+			p.i++
+		}
+	}
+	return out.String()
+}
+
+func (p *snippetParser) name() string {
+	start := p.i
+	for c := p.peek(); c == '_' || isAlnum(c); c = p.peek() {
+		p.i++
+	}
+	return p.src[start:p.i]
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// dollar renders a tab stop, placeholder, choice or variable starting at $
+func (p *snippetParser) dollar() string {
+	start := p.i
+	p.i++
+
+	if p.peek() != '{' {
+		name := p.name()
+		if isDigits(name) {
+			return ""
+		}
+		if name == "" || name[0] >= '0' && name[0] <= '9' {
+			// not a tab stop or variable, a plain $
+			p.i = start + 1
+			return "$"
+		}
+		return p.src[start:p.i]
+	}
+
+	p.i++
+	name := p.name()
+	if name == "" {
+		p.i = start + 1
+		return "$"
+	}
+	tabstop := isDigits(name)
+
+	text := ""
+	switch p.peek() {
+	case '}':
+	case ':':
+		p.i++
+		text = p.parse(true)
+	case '|':
+		p.i++
+		end := strings.Index(p.src[p.i:], "|}")
+		if end == -1 {
+			p.i = start + 1
+			return "$"
+		}
+		text = strings.SplitN(p.src[p.i:p.i+end], ",", 2)[0]
+		p.i += end + 1
+	default:
+		// variable transforms, skip to the closing brace
+		for depth := 1; p.i < len(p.src); p.i++ {
+			if p.src[p.i] == '\\' {
+				p.i++
+				continue
+			}
+			if p.src[p.i] == '{' {
+				depth++
+			}
+			if p.src[p.i] == '}' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+	}
+	if p.peek() == '}' {
+		p.i++
+	}
+
+	if tabstop {
+		return text
+	}
+	return p.src[start:p.i]
+}
+
+// stripJSONC removes comments and trailing commas, which VS Code allows in
+// snippet files.
+func stripJSONC(buf []byte) []byte {
+	out := []byte{}
+	inString := false
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(buf) {
+				i++
+				out = append(out, buf[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '/':
+			for i < len(buf) && buf[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '*':
+			end := bytes.Index(buf[i+2:], []byte("*/"))
+			if end == -1 {
+				return out
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			// drop a trailing comma before the closing bracket
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if bytes.HasSuffix(trimmed, []byte(",")) {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package pipetdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+var vscodeFile = `{
+	// a comment
+	"Print to console": {
+		"scope": "javascript,typescript",
+		"prefix": ["log", "cl"],
+		"body": [
+			"console.log('${1:msg}', $2);",
+			"$0"
+		],
+		"description": "Log output to console", /* trailing comma */
+	},
+	"Home": {
+		"prefix": "home",
+		"body": "cd \\$HOME // not a comment",
+	},
+}`
+
+func TestReadVSCode(t *testing.T) {
+	sns, err := ReadVSCode(strings.NewReader(vscodeFile))
+	assert.Nil(t, err, "should parse")
+	assert.Len(t, sns, 2, "two snippets")
+
+	assert.Equal(t, "Print to console", sns[0].Meta.Title, "name is the title")
+	assert.Equal(t, "console.log('msg', );\n", sns[0].Data, "defaults are filled in")
+	assert.Equal(t, []string{"javascript", "typescript"}, sns[0].Meta.Tags, "scope languages are tags")
+	assert.Equal(t, yaml.MapSlice{
+		{Key: "scope", Value: "javascript,typescript"},
+		{Key: "prefix", Value: []interface{}{"log", "cl"}},
+		{Key: "description", Value: "Log output to console"},
+		{Key: vscodeBody, Value: "console.log('${1:msg}', $2);\n$0"},
+	}, sns[0].Meta.Extra, "fields and the original body are kept")
+
+	assert.Equal(t, "cd $HOME // not a comment", sns[1].Data, "escapes are plain text")
+	_, ok := sns[1].Field(vscodeBody)
+	assert.False(t, ok, "no tab stops, body isn't kept")
+
+	again, err := ReadVSCode(strings.NewReader(vscodeFile))
+	assert.Nil(t, err, "should parse")
+	assert.Equal(t, sns[0].Meta.UID, again[0].Meta.UID, "uids are stable")
+}
+
+func TestVSCodeText(t *testing.T) {
+	cases := map[string]string{
+		"plain $":                           "plain $",
+		"$1 and ${2} gone":                  " and  gone",
+		"${1:outer ${2:inner}}":             "outer inner",
+		"${1|one,two|}":                     "one",
+		"echo $HOME ${USER:me}":             "echo $HOME ${USER:me}",
+		"${TM_FILENAME/(.*)/${1:/upcase}/}": "${TM_FILENAME/(.*)/${1:/upcase}/}",
+		`\$1 \} \\`:                         `$1 } \`,
+	}
+	for body, text := range cases {
+		assert.Equal(t, text, vscodeText(body), body)
+	}
+	assert.Equal(t, "a $b \\c", vscodeText(vscodeEscape("a $b \\c")), "escape round trips")
+}
+
+func TestWriteVSCode(t *testing.T) {
+	sns, err := ReadVSCode(strings.NewReader(vscodeFile))
+	assert.Nil(t, err, "should parse")
+
+	edited := *sns[1]
+	edited.Data = "cd $HOME/src\n"
+	plain := &Snippet{Meta: metadata{Title: "List Pods", Tags: []string{"k8s", "bash"}}, Data: "kubectl get pods"}
+	dup := &Snippet{Meta: metadata{Title: "List Pods"}, Data: "kubectl get po"}
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteVSCode(&buf, []*Snippet{sns[0], &edited, plain, dup}), "should write")
+
+	var out map[string]map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out), "valid json")
+
+	assert.Equal(t, []interface{}{"console.log('${1:msg}', $2);", "$0"}, out["Print to console"]["body"], "tab stops are written back")
+	assert.Equal(t, []interface{}{"log", "cl"}, out["Print to console"]["prefix"])
+	assert.Equal(t, "javascript,typescript", out["Print to console"]["scope"])
+
+	assert.Equal(t, []interface{}{`cd \$HOME/src`}, out["Home"]["body"], "edited text is escaped")
+
+	assert.Equal(t, "list-pods", out["List Pods"]["prefix"], "prefix from the title")
+	assert.Equal(t, "List Pods", out["List Pods"]["description"], "description from the title")
+	assert.Equal(t, "shellscript", out["List Pods"]["scope"], "scope from language tags")
+	assert.Contains(t, out, "List Pods (2)", "duplicate names are numbered")
+	assert.NotContains(t, out["List Pods (2)"], "scope", "no language, no scope")
+
+	back, err := ReadVSCode(&buf)
+	assert.Nil(t, err, "should read its own output")
+	assert.Equal(t, sns[0].Data, back[0].Data, "round trips")
+}